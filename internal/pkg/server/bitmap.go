@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EntrySETBIT struct {
+	Offset int `json:"offset"`
+	Value  int `json:"value"`
+}
+
+type EntryGETBIT struct {
+	Offset int `json:"offset"`
+}
+
+type EntryBITCOUNT struct {
+	Start int    `json:"start"`
+	End   *int   `json:"end,omitempty"`
+	Unit  string `json:"unit,omitempty"`
+}
+
+type EntryBITPOS struct {
+	Bit   int    `json:"bit"`
+	Start int    `json:"start"`
+	End   *int   `json:"end,omitempty"`
+	Unit  string `json:"unit,omitempty"`
+}
+
+type EntryBITOP struct {
+	Op   string   `json:"op"`
+	Dst  string   `json:"dst"`
+	Keys []string `json:"keys"`
+}
+
+// parseBitUnit reports whether unit is BIT and whether it is a valid unit
+// at all. The default unit is BYTE.
+func parseBitUnit(unit string) (bool, bool) {
+	switch strings.ToUpper(unit) {
+	case "", "BYTE":
+		return false, true
+	case "BIT":
+		return true, true
+	}
+	return false, false
+}
+
+func (r *Server) handlerSETBIT(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntrySETBIT
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.SETBIT(key, v.Offset, v.Value)
+	})
+}
+
+func (r *Server) handlerGETBIT(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryGETBIT
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.GETBIT(key, v.Offset)
+	})
+}
+
+func (r *Server) handlerBITCOUNT(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryBITCOUNT
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	bitUnit, ok := parseBitUnit(v.Unit)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": "WrongArgs",
+		})
+		return
+	}
+	end := -1
+	if v.End != nil {
+		end = *v.End
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.BITCOUNT(key, v.Start, end, bitUnit)
+	})
+}
+
+func (r *Server) handlerBITPOS(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryBITPOS
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	bitUnit, ok := parseBitUnit(v.Unit)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": "WrongArgs",
+		})
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.BITPOS(key, v.Bit, v.Start, v.End, bitUnit)
+	})
+}
+
+func (r *Server) handlerBITOP(ctx *gin.Context) {
+	var v EntryBITOP
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.BITOP(v.Op, v.Dst, v.Keys)
+	})
+}
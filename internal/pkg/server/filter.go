@@ -0,0 +1,127 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EntryReserve struct {
+	Capacity  int     `json:"capacity"`
+	ErrorRate float64 `json:"errorrate"`
+}
+
+func (r *Server) handlerBFRESERVE(ctx *gin.Context) {
+	r.respondReserve(ctx, r.store.BFRESERVE)
+}
+
+func (r *Server) handlerCFRESERVE(ctx *gin.Context) {
+	r.respondReserve(ctx, r.store.CFRESERVE)
+}
+
+func (r *Server) respondReserve(ctx *gin.Context, reserve func(key string, capacity int, errorRate float64) error) {
+	key := ctx.Param("key")
+
+	var v EntryReserve
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	if err := reserve(key, v.Capacity, v.ErrorRate); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (r *Server) handlerBFADD(ctx *gin.Context) {
+	r.respondFilterItem(ctx, r.store.BFADD)
+}
+
+func (r *Server) handlerBFEXISTS(ctx *gin.Context) {
+	r.respondFilterItem(ctx, r.store.BFEXISTS)
+}
+
+func (r *Server) handlerCFADDNX(ctx *gin.Context) {
+	r.respondFilterItem(ctx, r.store.CFADDNX)
+}
+
+func (r *Server) handlerCFEXISTS(ctx *gin.Context) {
+	r.respondFilterItem(ctx, r.store.CFEXISTS)
+}
+
+func (r *Server) handlerCFDEL(ctx *gin.Context) {
+	r.respondFilterItem(ctx, r.store.CFDEL)
+}
+
+func (r *Server) respondFilterItem(ctx *gin.Context, check func(key string, item any) (bool, error)) {
+	key := ctx.Param("key")
+
+	var v Entry
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	res, err := check(key, v.Value)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res,
+	})
+}
+
+func (r *Server) handlerBFMADD(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryArray
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	res, err := r.store.BFMADD(key, v.Value)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res,
+	})
+}
+
+func (r *Server) handlerCFADD(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v Entry
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	if err := r.store.CFADD(key, v.Value); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
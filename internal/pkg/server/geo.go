@@ -0,0 +1,144 @@
+package server
+
+import (
+	"encoding/json"
+	"golangProject/internal/pkg/storage"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EntryGEOADD struct {
+	Members []storage.GeoMember `json:"members"`
+}
+
+type EntryGEOPOS struct {
+	Members []string `json:"members"`
+}
+
+type EntryGEODIST struct {
+	Member1 string `json:"member1"`
+	Member2 string `json:"member2"`
+	Unit    string `json:"unit,omitempty"`
+}
+
+type EntryGEOSEARCH struct {
+	Member string            `json:"member,omitempty"`
+	Point  *storage.GeoPoint `json:"point,omitempty"`
+	Radius float64           `json:"radius,omitempty"`
+	Width  float64           `json:"width,omitempty"`
+	Height float64           `json:"height,omitempty"`
+	Unit   string            `json:"unit,omitempty"`
+	Order  string            `json:"order,omitempty"`
+	Count  int               `json:"count,omitempty"`
+}
+
+func (r *Server) handlerGEOADD(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryGEOADD
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.GEOADD(key, v.Members)
+	})
+}
+
+func (r *Server) handlerGEOPOS(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryGEOPOS
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	pos, err := r.store.GEOPOS(key, v.Members)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: pos,
+	})
+}
+
+func (r *Server) handlerGEODIST(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryGEODIST
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	dist, err := r.store.GEODIST(key, v.Member1, v.Member2, v.Unit)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if dist == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: *dist,
+	})
+}
+
+func (r *Server) handlerGEOSEARCH(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryGEOSEARCH
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	var desc bool
+	switch strings.ToUpper(v.Order) {
+	case "", "ASC":
+	case "DESC":
+		desc = true
+	default:
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": "WrongArgs",
+		})
+		return
+	}
+
+	res, err := r.store.GEOSEARCH(key, storage.GeoQuery{
+		FromMember: v.Member,
+		FromPoint:  v.Point,
+		Radius:     v.Radius,
+		Width:      v.Width,
+		Height:     v.Height,
+		Unit:       v.Unit,
+		Desc:       desc,
+		Count:      v.Count,
+	})
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res,
+	})
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (r *Server) handlerPFADD(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryArray
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	changed, err := r.store.PFADD(key, v.Value)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: changed,
+	})
+}
+
+func (r *Server) handlerPFCOUNT(ctx *gin.Context) {
+	var v EntryKeys
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.PFCOUNT(v.Keys)
+	})
+}
+
+func (r *Server) handlerPFMERGE(ctx *gin.Context) {
+	var v EntrySetStore
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	if err := r.store.PFMERGE(v.Dst, v.Keys); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
@@ -1,10 +1,15 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"golangProject/internal/pkg/storage"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,8 +24,17 @@ type Entry struct {
 }
 
 type EntrySet struct {
-	Value any    `json:"value"`
-	Ex    uint32 `json:"ex,omitempty"`
+	Value   any    `json:"value"`
+	Ex      uint32 `json:"ex,omitempty"`
+	Nx      bool   `json:"nx,omitempty"`
+	Xx      bool   `json:"xx,omitempty"`
+	Get     bool   `json:"get,omitempty"`
+	KeepTTL bool   `json:"keepttl,omitempty"`
+}
+
+type EntrySetResult struct {
+	Written bool `json:"written"`
+	Value   any  `json:"value,omitempty"`
 }
 
 type EntryArray struct {
@@ -40,6 +54,121 @@ type EntryLSET struct {
 	Value any `json:"value"`
 }
 
+type EntryIncr struct {
+	Value int `json:"value"`
+}
+
+type EntryRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type EntrySetRange struct {
+	Offset int    `json:"offset"`
+	Value  string `json:"value"`
+}
+
+type EntryString struct {
+	Value string `json:"value"`
+}
+
+type EntryGetEx struct {
+	Ex      uint32 `json:"ex,omitempty"`
+	Persist bool   `json:"persist,omitempty"`
+}
+
+type EntryMSet struct {
+	Value map[string]any `json:"value"`
+}
+
+type EntryFields struct {
+	Fields []string `json:"fields"`
+}
+
+type EntryHExpire struct {
+	Fields []string `json:"fields"`
+	Ex     int64    `json:"ex"`
+}
+
+type EntryLINSERT struct {
+	Position string `json:"position"`
+	Pivot    any    `json:"pivot"`
+	Value    any    `json:"value"`
+}
+
+type EntryLREM struct {
+	Count int `json:"count"`
+	Value any `json:"value"`
+}
+
+type EntryLPOS struct {
+	Value any  `json:"value"`
+	Rank  int  `json:"rank,omitempty"`
+	Count *int `json:"count,omitempty"`
+}
+
+type EntryOptionalRange struct {
+	Start int  `json:"start"`
+	End   *int `json:"end,omitempty"`
+}
+
+type EntryRangeUpdate struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+	Value int `json:"value"`
+}
+
+type EntryLCONCAT struct {
+	Src string `json:"src"`
+}
+
+type EntryLSPLIT struct {
+	Index int    `json:"index"`
+	Dst   string `json:"dst"`
+}
+
+type EntryLMOVE struct {
+	Src  string `json:"src"`
+	Dst  string `json:"dst"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type EntryBPOP struct {
+	Keys    []string `json:"keys"`
+	Timeout float64  `json:"timeout"`
+}
+
+type EntryBPOPResult struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+type EntryKeys struct {
+	Keys []string `json:"keys"`
+}
+
+type EntryPattern struct {
+	Pattern string `json:"pattern"`
+}
+
+type EntryScan struct {
+	Cursor string `json:"cursor"`
+	Match  string `json:"match"`
+	Count  int    `json:"count"`
+	Type   string `json:"type"`
+}
+
+type EntryLSCAN struct {
+	Cursor int `json:"cursor"`
+	Count  int `json:"count"`
+}
+
+type EntryScanResult struct {
+	Cursor any `json:"cursor"`
+	Value  any `json:"value"`
+}
+
 func New(st *storage.Storage) *Server {
 	s := &Server{
 		host:  ":8090",
@@ -59,8 +188,37 @@ func (r *Server) newAPI() *gin.Engine {
 	engine.POST("/scalar/set/:key", r.handlerSet)
 	engine.GET("/scalar/get/:key", r.handlerGet)
 
+	engine.GET("/scalar/mget", r.handlerMGET)
+	engine.POST("/scalar/mset", r.handlerMSET)
+	engine.POST("/scalar/msetnx", r.handlerMSETNX)
+
+	engine.POST("/scalar/incr/:key", r.handlerINCR)
+	engine.POST("/scalar/decr/:key", r.handlerDECR)
+	engine.POST("/scalar/incrby/:key", r.handlerINCRBY)
+	engine.POST("/scalar/decrby/:key", r.handlerDECRBY)
+
+	engine.POST("/scalar/append/:key", r.handlerAPPEND)
+	engine.GET("/scalar/strlen/:key", r.handlerSTRLEN)
+	engine.GET("/scalar/getrange/:key", r.handlerGETRANGE)
+	engine.POST("/scalar/setrange/:key", r.handlerSETRANGE)
+	engine.POST("/scalar/getset/:key", r.handlerGETSET)
+	engine.POST("/scalar/getdel/:key", r.handlerGETDEL)
+	engine.POST("/scalar/getex/:key", r.handlerGETEX)
+
 	engine.POST("/hash/set/:key/:field", r.handlerHSET)
 	engine.GET("/hash/get/:key/:field", r.handlerHGET)
+	engine.POST("/hash/incrby/:key/:field", r.handlerHINCRBY)
+	engine.POST("/hash/set/:key", r.handlerHMSET)
+	engine.POST("/hash/setnx/:key/:field", r.handlerHSETNX)
+	engine.POST("/hash/del/:key", r.handlerHDEL)
+	engine.GET("/hash/getall/:key", r.handlerHGETALL)
+	engine.GET("/hash/keys/:key", r.handlerHKEYS)
+	engine.GET("/hash/vals/:key", r.handlerHVALS)
+	engine.GET("/hash/len/:key", r.handlerHLEN)
+	engine.GET("/hash/exists/:key/:field", r.handlerHEXISTS)
+	engine.POST("/hash/expire/:key", r.handlerHEXPIRE)
+	engine.GET("/hash/ttl/:key", r.handlerHTTL)
+	engine.POST("/hash/persist/:key", r.handlerHPERSIST)
 
 	engine.POST("array/rpush/:key", r.handlerRPUSH)
 	engine.POST("array/raddtoset/:key", r.handlerRADDTOSET)
@@ -72,8 +230,122 @@ func (r *Server) newAPI() *gin.Engine {
 	engine.POST("array/lset/:key", r.handlerLSET)
 	engine.GET("array/lget/:key", r.handleLGET)
 
+	engine.GET("array/lrange/:key", r.handlerLRANGE)
+	engine.GET("array/llen/:key", r.handlerLLEN)
+
+	engine.POST("array/linsert/:key", r.handlerLINSERT)
+	engine.POST("array/lrem/:key", r.handlerLREM)
+	engine.POST("array/ltrim/:key", r.handlerLTRIM)
+	engine.GET("array/lpos/:key", r.handlerLPOS)
+
+	engine.POST("array/lreverse/:key", r.handlerLREVERSE)
+	engine.POST("array/lrotate/:key", r.handlerLROTATE)
+
+	engine.GET("array/lsum/:key", r.handlerLSUM)
+	engine.GET("array/lmin/:key", r.handlerLMIN)
+	engine.GET("array/lmax/:key", r.handlerLMAX)
+	engine.GET("array/lcount/:key", r.handlerLCOUNT)
+
+	engine.POST("array/lincrrange/:key", r.handlerLINCRRANGE)
+	engine.POST("array/lmulrange/:key", r.handlerLMULRANGE)
+
+	engine.POST("array/lconcat/:key", r.handlerLCONCAT)
+	engine.POST("array/lsplit/:key", r.handlerLSPLIT)
+
+	engine.POST("array/lmove", r.handlerLMOVE)
+	engine.POST("array/rpoplpush", r.handlerRPOPLPUSH)
+
+	engine.POST("array/blpop", r.handlerBLPOP)
+	engine.POST("array/brpop", r.handlerBRPOP)
+
+	engine.POST("/set/sadd/:key", r.handlerSADD)
+	engine.POST("/set/srem/:key", r.handlerSREM)
+	engine.GET("/set/sismember/:key", r.handlerSISMEMBER)
+	engine.GET("/set/scard/:key", r.handlerSCARD)
+	engine.GET("/set/smembers/:key", r.handlerSMEMBERS)
+	engine.POST("/set/spop/:key", r.handlerSPOP)
+	engine.GET("/set/srandmember/:key", r.handlerSRANDMEMBER)
+
+	engine.GET("/set/sunion", r.handlerSUNION)
+	engine.GET("/set/sinter", r.handlerSINTER)
+	engine.GET("/set/sdiff", r.handlerSDIFF)
+	engine.POST("/set/sunionstore", r.handlerSUNIONSTORE)
+	engine.POST("/set/sinterstore", r.handlerSINTERSTORE)
+	engine.POST("/set/sdiffstore", r.handlerSDIFFSTORE)
+
+	engine.POST("/zset/zadd/:key", r.handlerZADD)
+	engine.POST("/zset/zrem/:key", r.handlerZREM)
+	engine.GET("/zset/zcard/:key", r.handlerZCARD)
+	engine.GET("/zset/zscore/:key", r.handlerZSCORE)
+	engine.POST("/zset/zincrby/:key", r.handlerZINCRBY)
+	engine.GET("/zset/zrank/:key", r.handlerZRANK)
+	engine.GET("/zset/zrevrank/:key", r.handlerZREVRANK)
+	engine.GET("/zset/zrange/:key", r.handlerZRANGE)
+	engine.POST("/zset/zpopmin/:key", r.handlerZPOPMIN)
+	engine.POST("/zset/zpopmax/:key", r.handlerZPOPMAX)
+
+	engine.POST("/stream/xadd/:key", r.handlerXADD)
+	engine.GET("/stream/xlen/:key", r.handlerXLEN)
+	engine.GET("/stream/xrange/:key", r.handlerXRANGE)
+	engine.GET("/stream/xrevrange/:key", r.handlerXREVRANGE)
+	engine.POST("/stream/xgroup/create/:key", r.handlerXGROUPCREATE)
+	engine.POST("/stream/xreadgroup/:key", r.handlerXREADGROUP)
+	engine.POST("/stream/xack/:key", r.handlerXACK)
+	engine.GET("/stream/xpending/:key", r.handlerXPENDING)
+	engine.POST("/stream/xclaim/:key", r.handlerXCLAIM)
+
+	engine.POST("/bitmap/setbit/:key", r.handlerSETBIT)
+	engine.GET("/bitmap/getbit/:key", r.handlerGETBIT)
+	engine.GET("/bitmap/bitcount/:key", r.handlerBITCOUNT)
+	engine.GET("/bitmap/bitpos/:key", r.handlerBITPOS)
+	engine.POST("/bitmap/bitop", r.handlerBITOP)
+
+	engine.POST("/hll/pfadd/:key", r.handlerPFADD)
+	engine.GET("/hll/pfcount", r.handlerPFCOUNT)
+	engine.POST("/hll/pfmerge", r.handlerPFMERGE)
+
+	engine.POST("/bloom/reserve/:key", r.handlerBFRESERVE)
+	engine.POST("/bloom/add/:key", r.handlerBFADD)
+	engine.POST("/bloom/madd/:key", r.handlerBFMADD)
+	engine.GET("/bloom/exists/:key", r.handlerBFEXISTS)
+
+	engine.POST("/bloom/cf/reserve/:key", r.handlerCFRESERVE)
+	engine.POST("/bloom/cf/add/:key", r.handlerCFADD)
+	engine.POST("/bloom/cf/addnx/:key", r.handlerCFADDNX)
+	engine.GET("/bloom/cf/exists/:key", r.handlerCFEXISTS)
+	engine.POST("/bloom/cf/del/:key", r.handlerCFDEL)
+
+	engine.POST("/geo/add/:key", r.handlerGEOADD)
+	engine.GET("/geo/pos/:key", r.handlerGEOPOS)
+	engine.GET("/geo/dist/:key", r.handlerGEODIST)
+	engine.GET("/geo/search/:key", r.handlerGEOSEARCH)
+
+	engine.POST("/cms/initbydim/:key", r.handlerCMSINITBYDIM)
+	engine.POST("/cms/initbyprob/:key", r.handlerCMSINITBYPROB)
+	engine.POST("/cms/incrby/:key", r.handlerCMSINCRBY)
+	engine.GET("/cms/query/:key", r.handlerCMSQUERY)
+	engine.POST("/cms/merge", r.handlerCMSMERGE)
+
+	engine.POST("/topk/reserve/:key", r.handlerTOPKRESERVE)
+	engine.POST("/topk/add/:key", r.handlerTOPKADD)
+	engine.GET("/topk/list/:key", r.handlerTOPKLIST)
+
+	engine.POST("/ts/create/:key", r.handlerTSCREATE)
+	engine.POST("/ts/add/:key", r.handlerTSADD)
+	engine.GET("/ts/range/:key", r.handlerTSRANGE)
+	engine.POST("/ts/createrule", r.handlerTSCREATERULE)
+	engine.POST("/ts/deleterule", r.handlerTSDELETERULE)
+
 	engine.POST("/expire/:key", r.handlerExpire)
 
+	engine.GET("/keys", r.handlerKEYS)
+	engine.POST("/keys/del", r.handlerDEL)
+	engine.GET("/keys/exists", r.handlerEXISTS)
+	engine.GET("/keys/type/:key", r.handlerTYPE)
+	engine.GET("/keys/scan", r.handlerSCAN)
+	engine.GET("/hash/scan/:key", r.handlerHSCAN)
+	engine.GET("array/scan/:key", r.handlerLSCAN)
+
 	return engine
 }
 
@@ -86,7 +358,21 @@ func (r *Server) handlerSet(ctx *gin.Context) {
 		return
 	}
 
-	err := r.store.SET(key, v.Value, int64(v.Ex))
+	opts := make([]storage.SetOption, 0)
+	if v.Nx {
+		opts = append(opts, storage.SetNX())
+	}
+	if v.Xx {
+		opts = append(opts, storage.SetXX())
+	}
+	if v.Get {
+		opts = append(opts, storage.SetGet())
+	}
+	if v.KeepTTL {
+		opts = append(opts, storage.SetKeepTTL())
+	}
+
+	written, old, err := r.store.SET(key, v.Value, int64(v.Ex), opts...)
 	if err != nil {
 		fmt.Println(err)
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
@@ -95,6 +381,14 @@ func (r *Server) handlerSet(ctx *gin.Context) {
 		})
 		return
 	}
+
+	res := EntrySetResult{
+		Written: written,
+	}
+	if old != nil {
+		res.Value = *old
+	}
+	ctx.JSON(http.StatusOK, res)
 }
 
 func (r *Server) handlerGet(ctx *gin.Context) {
@@ -111,53 +405,98 @@ func (r *Server) handlerGet(ctx *gin.Context) {
 	})
 }
 
-func (r *Server) handlerHSET(ctx *gin.Context) {
-	key := ctx.Param("key")
-	field := ctx.Param("field")
+func (r *Server) handlerMGET(ctx *gin.Context) {
+	var v EntryKeys
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
 
-	var v Entry
+	vals := r.store.MGET(v.Keys)
+	res := make([]any, len(vals))
+	for i, val := range vals {
+		if val != nil {
+			res[i] = *val
+		}
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res,
+	})
+}
+
+func (r *Server) handlerMSET(ctx *gin.Context) {
+	var v EntryMSet
 	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
 		ctx.AbortWithStatus(http.StatusBadGateway)
 		return
 	}
 
-	err := r.store.HSET(key, field, v.Value)
+	err := r.store.MSET(v.Value)
 	if err != nil {
-		fmt.Println(err)
 		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
 			"status":  false,
 			"message": err.Error(),
 		})
 		return
 	}
+
+	ctx.Status(http.StatusOK)
 }
 
-func (r *Server) handlerHGET(ctx *gin.Context) {
+func (r *Server) handlerMSETNX(ctx *gin.Context) {
+	var v EntryMSet
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	written, err := r.store.MSETNX(v.Value)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, EntrySetResult{
+		Written: written,
+	})
+}
+
+func (r *Server) handlerAPPEND(ctx *gin.Context) {
 	key := ctx.Param("key")
-	field := ctx.Param("field")
 
-	v := r.store.HGET(key, field)
-	if v == nil {
-		ctx.AbortWithStatus(http.StatusNotFound)
+	var v EntryString
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, Entry{
-		Value: *v,
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.APPEND(key, v.Value)
 	})
 }
 
-func (r *Server) handlerRPUSH(ctx *gin.Context) {
+func (r *Server) handlerSTRLEN(ctx *gin.Context) {
 	key := ctx.Param("key")
 
-	var v EntryArray
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.STRLEN(key)
+	})
+}
+
+func (r *Server) handlerGETRANGE(ctx *gin.Context) {
+	key := ctx.Param("key")
 
+	var v EntryRange
 	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
 		ctx.AbortWithStatus(http.StatusBadGateway)
 		return
 	}
 
-	err := r.store.RPUSH(key, v.Value)
+	res, err := r.store.GETRANGE(key, v.Start, v.End)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
 			"status":  false,
@@ -166,20 +505,35 @@ func (r *Server) handlerRPUSH(ctx *gin.Context) {
 		return
 	}
 
-	ctx.Status(http.StatusOK)
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res,
+	})
 }
 
-func (r *Server) handlerRADDTOSET(ctx *gin.Context) {
+func (r *Server) handlerSETRANGE(ctx *gin.Context) {
 	key := ctx.Param("key")
 
-	var v EntryArray
+	var v EntrySetRange
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.SETRANGE(key, v.Offset, v.Value)
+	})
+}
+
+func (r *Server) handlerGETSET(ctx *gin.Context) {
+	key := ctx.Param("key")
 
+	var v Entry
 	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
 		ctx.AbortWithStatus(http.StatusBadGateway)
 		return
 	}
 
-	err := r.store.RADDTOSET(key, v.Value)
+	old, err := r.store.GETSET(key, v.Value)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
 			"status":  false,
@@ -188,20 +542,39 @@ func (r *Server) handlerRADDTOSET(ctx *gin.Context) {
 		return
 	}
 
-	ctx.Status(http.StatusOK)
+	if old == nil {
+		ctx.JSON(http.StatusOK, Entry{})
+		return
+	}
+	ctx.JSON(http.StatusOK, Entry{
+		Value: *old,
+	})
 }
 
-func (r *Server) handlerRPOP(ctx *gin.Context) {
+func (r *Server) handlerGETDEL(ctx *gin.Context) {
 	key := ctx.Param("key")
 
-	var v EntryListPOP
+	r.respondScalar(ctx, func() (*any, error) {
+		return r.store.GETDEL(key)
+	})
+}
 
-	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+func (r *Server) handlerGETEX(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryGetEx
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
 		ctx.AbortWithStatus(http.StatusBadGateway)
 		return
 	}
 
-	vals, err := r.store.RPOP(key, v.Slices)
+	r.respondScalar(ctx, func() (*any, error) {
+		return r.store.GETEX(key, int64(v.Ex), v.Persist)
+	})
+}
+
+func (r *Server) respondScalar(ctx *gin.Context, get func() (*any, error)) {
+	v, err := get()
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
 			"status":  false,
@@ -209,23 +582,77 @@ func (r *Server) handlerRPOP(ctx *gin.Context) {
 		})
 		return
 	}
+	if v == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
 
 	ctx.JSON(http.StatusOK, Entry{
-		Value: vals,
+		Value: *v,
 	})
 }
 
-func (r *Server) handlerLPUSH(ctx *gin.Context) {
+func (r *Server) handlerINCR(ctx *gin.Context) {
 	key := ctx.Param("key")
 
-	var v EntryArray
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.INCR(key)
+	})
+}
+
+func (r *Server) handlerDECR(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.DECR(key)
+	})
+}
 
+func (r *Server) handlerINCRBY(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryIncr
 	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
 		ctx.AbortWithStatus(http.StatusBadGateway)
 		return
 	}
 
-	err := r.store.LPUSH(key, v.Value)
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.INCRBY(key, v.Value)
+	})
+}
+
+func (r *Server) handlerDECRBY(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryIncr
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.DECRBY(key, v.Value)
+	})
+}
+
+func (r *Server) handlerHINCRBY(ctx *gin.Context) {
+	key := ctx.Param("key")
+	field := ctx.Param("field")
+
+	var v EntryIncr
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.HINCRBY(key, field, v.Value)
+	})
+}
+
+func (r *Server) respondCounter(ctx *gin.Context, incr func() (int, error)) {
+	res, err := incr()
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
 			"status":  false,
@@ -234,21 +661,24 @@ func (r *Server) handlerLPUSH(ctx *gin.Context) {
 		return
 	}
 
-	ctx.Status(http.StatusOK)
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res,
+	})
 }
 
-func (r *Server) handleLPOP(ctx *gin.Context) {
+func (r *Server) handlerHSET(ctx *gin.Context) {
 	key := ctx.Param("key")
+	field := ctx.Param("field")
 
-	var v EntryListPOP
-
+	var v Entry
 	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
 		ctx.AbortWithStatus(http.StatusBadGateway)
 		return
 	}
 
-	vals, err := r.store.LPOP(key, v.Slices)
+	created, err := r.store.HSET(key, map[string]any{field: v.Value})
 	if err != nil {
+		fmt.Println(err)
 		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
 			"status":  false,
 			"message": err.Error(),
@@ -257,24 +687,35 @@ func (r *Server) handleLPOP(ctx *gin.Context) {
 	}
 
 	ctx.JSON(http.StatusOK, Entry{
-		Value: vals,
+		Value: created,
 	})
 }
 
-func (r *Server) handlerLSET(ctx *gin.Context) {
+func (r *Server) handlerHMSET(ctx *gin.Context) {
 	key := ctx.Param("key")
 
-	var v EntryLSET
+	var v EntryMSet
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.HSET(key, v.Value)
+	})
+}
 
+func (r *Server) handlerHSETNX(ctx *gin.Context) {
+	key := ctx.Param("key")
+	field := ctx.Param("field")
+
+	var v Entry
 	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
-			"status":  false,
-			"message": err.Error(),
-		})
+		ctx.AbortWithStatus(http.StatusBadGateway)
 		return
 	}
 
-	err := r.store.LSET(key, v.Index, v.Value)
+	written, err := r.store.HSETNX(key, field, v.Value)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
 			"status":  false,
@@ -283,20 +724,29 @@ func (r *Server) handlerLSET(ctx *gin.Context) {
 		return
 	}
 
-	ctx.Status(http.StatusOK)
+	ctx.JSON(http.StatusOK, EntrySetResult{
+		Written: written,
+	})
 }
 
-func (r *Server) handleLGET(ctx *gin.Context) {
+func (r *Server) handlerHDEL(ctx *gin.Context) {
 	key := ctx.Param("key")
 
-	var v EntryLGET
-
+	var v EntryFields
 	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
 		ctx.AbortWithStatus(http.StatusBadGateway)
 		return
 	}
 
-	vals, err := r.store.LGET(key, v.Index)
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.HDEL(key, v.Fields)
+	})
+}
+
+func (r *Server) handlerHGETALL(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	fields, err := r.store.HGETALL(key)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
 			"status":  false,
@@ -306,23 +756,810 @@ func (r *Server) handleLGET(ctx *gin.Context) {
 	}
 
 	ctx.JSON(http.StatusOK, Entry{
-		Value: vals,
+		Value: fields,
 	})
 }
 
-func (r *Server) handlerExpire(ctx *gin.Context) {
+func (r *Server) handlerHKEYS(ctx *gin.Context) {
 	key := ctx.Param("key")
 
-	var v Entry
-	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
-		ctx.AbortWithStatus(http.StatusBadGateway)
+	fields, err := r.store.HKEYS(key)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
 		return
 	}
 
-	expireCode := r.store.Expire(key, int64(v.Value.(float64)))
+	ctx.JSON(http.StatusOK, Entry{
+		Value: fields,
+	})
+}
+
+func (r *Server) handlerHVALS(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	vals, err := r.store.HVALS(key)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
 
 	ctx.JSON(http.StatusOK, Entry{
-		Value: expireCode,
+		Value: vals,
+	})
+}
+
+func (r *Server) handlerHLEN(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.HLEN(key)
+	})
+}
+
+func (r *Server) handlerHEXISTS(ctx *gin.Context) {
+	key := ctx.Param("key")
+	field := ctx.Param("field")
+
+	exists, err := r.store.HEXISTS(key, field)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: exists,
+	})
+}
+
+func (r *Server) handlerHGET(ctx *gin.Context) {
+	key := ctx.Param("key")
+	field := ctx.Param("field")
+
+	v := r.store.HGET(key, field)
+	if v == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: *v,
+	})
+}
+
+func (r *Server) handlerHEXPIRE(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryHExpire
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	codes, err := r.store.HEXPIRE(key, v.Fields, v.Ex)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: codes,
+	})
+}
+
+func (r *Server) handlerHTTL(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryFields
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	ttls, err := r.store.HTTL(key, v.Fields)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: ttls,
+	})
+}
+
+func (r *Server) handlerHPERSIST(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryFields
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	codes, err := r.store.HPERSIST(key, v.Fields)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: codes,
+	})
+}
+
+func (r *Server) handlerRPUSH(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryArray
+
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	err := r.store.RPUSH(key, v.Value)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (r *Server) handlerRADDTOSET(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryArray
+
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	err := r.store.RADDTOSET(key, v.Value)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (r *Server) handlerRPOP(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryListPOP
+
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	vals, err := r.store.RPOP(key, v.Slices)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: vals,
+	})
+}
+
+func (r *Server) handlerLPUSH(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryArray
+
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	err := r.store.LPUSH(key, v.Value)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (r *Server) handleLPOP(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryListPOP
+
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	vals, err := r.store.LPOP(key, v.Slices)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: vals,
+	})
+}
+
+func (r *Server) handlerLSET(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryLSET
+
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err := r.store.LSET(key, v.Index, v.Value)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (r *Server) handleLGET(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryLGET
+
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	vals, err := r.store.LGET(key, v.Index)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: vals,
+	})
+}
+
+func (r *Server) handlerLRANGE(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryRange
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	vals, err := r.store.LRANGE(key, v.Start, v.End)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: vals,
+	})
+}
+
+func (r *Server) handlerLLEN(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.LLEN(key)
+	})
+}
+
+func (r *Server) handlerLINSERT(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryLINSERT
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	var before bool
+	switch strings.ToUpper(v.Position) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": "WrongArgs",
+		})
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.LINSERT(key, before, v.Pivot, v.Value)
+	})
+}
+
+func (r *Server) handlerLREM(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryLREM
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.LREM(key, v.Count, v.Value)
+	})
+}
+
+func (r *Server) handlerLTRIM(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryRange
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	err := r.store.LTRIM(key, v.Start, v.End)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (r *Server) handlerLPOS(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryLPOS
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	rank := v.Rank
+	if rank == 0 {
+		rank = 1
+	}
+	count := 1
+	if v.Count != nil {
+		count = *v.Count
+	}
+
+	pos, err := r.store.LPOS(key, v.Value, rank, count)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if v.Count != nil {
+		ctx.JSON(http.StatusOK, Entry{
+			Value: pos,
+		})
+		return
+	}
+	if len(pos) == 0 {
+		ctx.JSON(http.StatusOK, Entry{})
+		return
+	}
+	ctx.JSON(http.StatusOK, Entry{
+		Value: pos[0],
+	})
+}
+
+func (r *Server) handlerLREVERSE(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryOptionalRange
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	end := -1
+	if v.End != nil {
+		end = *v.End
+	}
+
+	err := r.store.LREVERSE(key, v.Start, end)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (r *Server) handlerLROTATE(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryIncr
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	err := r.store.LROTATE(key, v.Value)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (r *Server) handlerLSUM(ctx *gin.Context) {
+	r.respondAggregate(ctx, r.store.LSUM)
+}
+
+func (r *Server) handlerLMIN(ctx *gin.Context) {
+	r.respondAggregate(ctx, r.store.LMIN)
+}
+
+func (r *Server) handlerLMAX(ctx *gin.Context) {
+	r.respondAggregate(ctx, r.store.LMAX)
+}
+
+func (r *Server) handlerLCOUNT(ctx *gin.Context) {
+	r.respondAggregate(ctx, r.store.LCOUNT)
+}
+
+func (r *Server) respondAggregate(ctx *gin.Context, agg func(key string, start int, stop int) (int, error)) {
+	key := ctx.Param("key")
+
+	v := EntryOptionalRange{}
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	end := -1
+	if v.End != nil {
+		end = *v.End
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return agg(key, v.Start, end)
+	})
+}
+
+func (r *Server) handlerLINCRRANGE(ctx *gin.Context) {
+	r.respondRangeUpdate(ctx, r.store.LINCRRANGE)
+}
+
+func (r *Server) handlerLMULRANGE(ctx *gin.Context) {
+	r.respondRangeUpdate(ctx, r.store.LMULRANGE)
+}
+
+func (r *Server) respondRangeUpdate(ctx *gin.Context, upd func(key string, start int, stop int, val int) error) {
+	key := ctx.Param("key")
+
+	var v EntryRangeUpdate
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	err := upd(key, v.Start, v.End, v.Value)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (r *Server) handlerLCONCAT(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryLCONCAT
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.LCONCAT(key, v.Src)
+	})
+}
+
+func (r *Server) handlerLSPLIT(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryLSPLIT
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.LSPLIT(key, v.Index, v.Dst)
+	})
+}
+
+func (r *Server) handlerLMOVE(ctx *gin.Context) {
+	var v EntryLMOVE
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	fromLeft, okFrom := parseSide(v.From)
+	toLeft, okTo := parseSide(v.To)
+	if !okFrom || !okTo {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": "WrongArgs",
+		})
+		return
+	}
+
+	r.respondScalar(ctx, func() (*any, error) {
+		return r.store.LMOVE(v.Src, v.Dst, fromLeft, toLeft)
+	})
+}
+
+func (r *Server) handlerRPOPLPUSH(ctx *gin.Context) {
+	var v EntryLMOVE
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondScalar(ctx, func() (*any, error) {
+		return r.store.RPOPLPUSH(v.Src, v.Dst)
+	})
+}
+
+func (r *Server) handlerBLPOP(ctx *gin.Context) {
+	r.respondBlockingPop(ctx, r.store.BLPOP)
+}
+
+func (r *Server) handlerBRPOP(ctx *gin.Context) {
+	r.respondBlockingPop(ctx, r.store.BRPOP)
+}
+
+// respondBlockingPop holds the request open until an element is popped,
+// the timeout expires or the client disconnects.
+func (r *Server) respondBlockingPop(ctx *gin.Context, pop func(context.Context, []string, time.Duration) (string, *any, error)) {
+	var v EntryBPOP
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	timeout := time.Duration(v.Timeout * float64(time.Second))
+	key, val, err := pop(ctx.Request.Context(), v.Keys, timeout)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		ctx.Abort()
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if val == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, EntryBPOPResult{
+		Key:   key,
+		Value: *val,
+	})
+}
+
+// parseSide reports whether side is LEFT and whether it is a valid side at all.
+func parseSide(side string) (bool, bool) {
+	switch strings.ToUpper(side) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+func (r *Server) handlerExpire(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v Entry
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	expireCode := r.store.Expire(key, int64(v.Value.(float64)))
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: expireCode,
+	})
+}
+
+func (r *Server) handlerDEL(ctx *gin.Context) {
+	var v EntryKeys
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: r.store.DEL(v.Keys),
+	})
+}
+
+func (r *Server) handlerEXISTS(ctx *gin.Context) {
+	var v EntryKeys
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: r.store.EXISTS(v.Keys),
+	})
+}
+
+func (r *Server) handlerTYPE(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: r.store.TYPE(key),
+	})
+}
+
+func (r *Server) handlerKEYS(ctx *gin.Context) {
+	var v EntryPattern
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: r.store.KEYS(v.Pattern),
+	})
+}
+
+func (r *Server) handlerSCAN(ctx *gin.Context) {
+	var v EntryScan
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	var sKind storage.StructKind
+	if v.Type != "" {
+		var ok bool
+		if sKind, ok = storage.ParseStructKind(v.Type); !ok {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"status":  false,
+				"message": "WrongArgs",
+			})
+			return
+		}
+	}
+
+	cursor, keys := r.store.SCAN(v.Cursor, v.Match, v.Count, sKind)
+
+	ctx.JSON(http.StatusOK, EntryScanResult{
+		Cursor: cursor,
+		Value:  keys,
+	})
+}
+
+func (r *Server) handlerHSCAN(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryScan
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	cursor, fields, err := r.store.HSCAN(key, v.Cursor, v.Match, v.Count)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, EntryScanResult{
+		Cursor: cursor,
+		Value:  fields,
+	})
+}
+
+func (r *Server) handlerLSCAN(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryLSCAN
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	cursor, vals, err := r.store.LSCAN(key, v.Cursor, v.Count)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, EntryScanResult{
+		Cursor: cursor,
+		Value:  vals,
 	})
 }
 
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EntryCount struct {
+	Count *int `json:"count,omitempty"`
+}
+
+type EntrySetStore struct {
+	Dst  string   `json:"dst"`
+	Keys []string `json:"keys"`
+}
+
+func (r *Server) handlerSADD(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryArray
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.SADD(key, v.Value)
+	})
+}
+
+func (r *Server) handlerSREM(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryArray
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.SREM(key, v.Value)
+	})
+}
+
+func (r *Server) handlerSISMEMBER(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v Entry
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	ok, err := r.store.SISMEMBER(key, v.Value)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: ok,
+	})
+}
+
+func (r *Server) handlerSCARD(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.SCARD(key)
+	})
+}
+
+func (r *Server) handlerSMEMBERS(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	r.respondValues(ctx, func() ([]any, error) {
+		return r.store.SMEMBERS(key)
+	})
+}
+
+func (r *Server) handlerSPOP(ctx *gin.Context) {
+	r.respondRandom(ctx, r.store.SPOP)
+}
+
+func (r *Server) handlerSRANDMEMBER(ctx *gin.Context) {
+	r.respondRandom(ctx, r.store.SRANDMEMBER)
+}
+
+// respondRandom answers with a single member when count is omitted
+// and with a list of members otherwise.
+func (r *Server) respondRandom(ctx *gin.Context, get func(key string, count int) ([]any, error)) {
+	key := ctx.Param("key")
+
+	var v EntryCount
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	count := 1
+	if v.Count != nil {
+		count = *v.Count
+	}
+
+	res, err := get(key, count)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if v.Count != nil {
+		ctx.JSON(http.StatusOK, Entry{
+			Value: res,
+		})
+		return
+	}
+	if len(res) == 0 {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res[0],
+	})
+}
+
+func (r *Server) handlerSUNION(ctx *gin.Context) {
+	r.respondSetAlgebra(ctx, r.store.SUNION)
+}
+
+func (r *Server) handlerSINTER(ctx *gin.Context) {
+	r.respondSetAlgebra(ctx, r.store.SINTER)
+}
+
+func (r *Server) handlerSDIFF(ctx *gin.Context) {
+	r.respondSetAlgebra(ctx, r.store.SDIFF)
+}
+
+func (r *Server) respondSetAlgebra(ctx *gin.Context, op func(keys []string) ([]any, error)) {
+	var v EntryKeys
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondValues(ctx, func() ([]any, error) {
+		return op(v.Keys)
+	})
+}
+
+func (r *Server) handlerSUNIONSTORE(ctx *gin.Context) {
+	r.respondSetAlgebraStore(ctx, r.store.SUNIONSTORE)
+}
+
+func (r *Server) handlerSINTERSTORE(ctx *gin.Context) {
+	r.respondSetAlgebraStore(ctx, r.store.SINTERSTORE)
+}
+
+func (r *Server) handlerSDIFFSTORE(ctx *gin.Context) {
+	r.respondSetAlgebraStore(ctx, r.store.SDIFFSTORE)
+}
+
+func (r *Server) respondSetAlgebraStore(ctx *gin.Context, op func(dst string, keys []string) (int, error)) {
+	var v EntrySetStore
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return op(v.Dst, v.Keys)
+	})
+}
+
+func (r *Server) respondValues(ctx *gin.Context, get func() ([]any, error)) {
+	res, err := get()
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res,
+	})
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"encoding/json"
+	"golangProject/internal/pkg/storage"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EntryCMSINITBYDIM struct {
+	Width int `json:"width"`
+	Depth int `json:"depth"`
+}
+
+type EntryCMSINITBYPROB struct {
+	Error       float64 `json:"error"`
+	Probability float64 `json:"probability"`
+}
+
+type EntryCMSINCRBY struct {
+	Items []storage.ItemCount `json:"items"`
+}
+
+type EntryCMSMERGE struct {
+	Dst     string   `json:"dst"`
+	Keys    []string `json:"keys"`
+	Weights []int    `json:"weights,omitempty"`
+}
+
+type EntryTOPKRESERVE struct {
+	K     int     `json:"k"`
+	Width int     `json:"width,omitempty"`
+	Depth int     `json:"depth,omitempty"`
+	Decay float64 `json:"decay,omitempty"`
+}
+
+func (r *Server) handlerCMSINITBYDIM(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryCMSINITBYDIM
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondInit(ctx, func() error {
+		return r.store.CMSINITBYDIM(key, v.Width, v.Depth)
+	})
+}
+
+func (r *Server) handlerCMSINITBYPROB(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryCMSINITBYPROB
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondInit(ctx, func() error {
+		return r.store.CMSINITBYPROB(key, v.Error, v.Probability)
+	})
+}
+
+func (r *Server) handlerTOPKRESERVE(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryTOPKRESERVE
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondInit(ctx, func() error {
+		return r.store.TOPKRESERVE(key, v.K, v.Width, v.Depth, v.Decay)
+	})
+}
+
+func (r *Server) respondInit(ctx *gin.Context, init func() error) {
+	if err := init(); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (r *Server) handlerCMSINCRBY(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryCMSINCRBY
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounts(ctx, func() ([]int, error) {
+		return r.store.CMSINCRBY(key, v.Items)
+	})
+}
+
+func (r *Server) handlerCMSQUERY(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryArray
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounts(ctx, func() ([]int, error) {
+		return r.store.CMSQUERY(key, v.Value)
+	})
+}
+
+func (r *Server) respondCounts(ctx *gin.Context, get func() ([]int, error)) {
+	res, err := get()
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res,
+	})
+}
+
+func (r *Server) handlerCMSMERGE(ctx *gin.Context) {
+	var v EntryCMSMERGE
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondInit(ctx, func() error {
+		return r.store.CMSMERGE(v.Dst, v.Keys, v.Weights)
+	})
+}
+
+func (r *Server) handlerTOPKADD(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryArray
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondValues(ctx, func() ([]any, error) {
+		return r.store.TOPKADD(key, v.Value)
+	})
+}
+
+func (r *Server) handlerTOPKLIST(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	top, err := r.store.TOPKLIST(key)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: top,
+	})
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"golangProject/internal/pkg/storage"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EntryXADD struct {
+	ID     string         `json:"id,omitempty"`
+	Fields map[string]any `json:"fields"`
+	MaxLen int            `json:"maxlen,omitempty"`
+}
+
+type EntryXRANGE struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	Count *int   `json:"count,omitempty"`
+}
+
+type EntryXGROUP struct {
+	Group    string `json:"group"`
+	ID       string `json:"id,omitempty"`
+	MkStream bool   `json:"mkstream,omitempty"`
+}
+
+type EntryXREADGROUP struct {
+	Group    string   `json:"group"`
+	Consumer string   `json:"consumer"`
+	ID       string   `json:"id,omitempty"`
+	Count    *int     `json:"count,omitempty"`
+	Block    *float64 `json:"block,omitempty"`
+}
+
+type EntryXACK struct {
+	Group string   `json:"group"`
+	IDs   []string `json:"ids"`
+}
+
+type EntryGroup struct {
+	Group string `json:"group"`
+}
+
+type EntryXCLAIM struct {
+	Group    string   `json:"group"`
+	Consumer string   `json:"consumer"`
+	MinIdle  int64    `json:"minidle"`
+	IDs      []string `json:"ids"`
+}
+
+func (r *Server) handlerXADD(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryXADD
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	id, err := r.store.XADD(key, v.ID, v.Fields, v.MaxLen)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: id,
+	})
+}
+
+func (r *Server) handlerXLEN(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.XLEN(key)
+	})
+}
+
+func (r *Server) handlerXRANGE(ctx *gin.Context) {
+	r.respondXRange(ctx, r.store.XRANGE, "-", "+")
+}
+
+func (r *Server) handlerXREVRANGE(ctx *gin.Context) {
+	r.respondXRange(ctx, r.store.XREVRANGE, "+", "-")
+}
+
+func (r *Server) respondXRange(ctx *gin.Context, get func(key string, from string, to string, count int) ([]storage.StreamEntry, error), from string, to string) {
+	key := ctx.Param("key")
+
+	v := EntryXRANGE{Start: from, End: to}
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	count := -1
+	if v.Count != nil {
+		count = *v.Count
+	}
+
+	r.respondStreamEntries(ctx, func() ([]storage.StreamEntry, error) {
+		return get(key, v.Start, v.End, count)
+	})
+}
+
+func (r *Server) handlerXGROUPCREATE(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	v := EntryXGROUP{ID: "$"}
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	if err := r.store.XGROUPCREATE(key, v.Group, v.ID, v.MkStream); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (r *Server) handlerXREADGROUP(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	v := EntryXREADGROUP{ID: ">"}
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	count := -1
+	if v.Count != nil {
+		count = *v.Count
+	}
+
+	if v.Block == nil {
+		r.respondStreamEntries(ctx, func() ([]storage.StreamEntry, error) {
+			return r.store.XREADGROUP(key, v.Group, v.Consumer, v.ID, count)
+		})
+		return
+	}
+
+	if v.ID != ">" {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": "WrongArgs",
+		})
+		return
+	}
+
+	timeout := time.Duration(*v.Block * float64(time.Second))
+	res, err := r.store.BXREADGROUP(ctx.Request.Context(), key, v.Group, v.Consumer, count, timeout)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		ctx.Abort()
+		return
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if len(res) == 0 {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res,
+	})
+}
+
+func (r *Server) handlerXACK(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryXACK
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.XACK(key, v.Group, v.IDs)
+	})
+}
+
+func (r *Server) handlerXPENDING(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryGroup
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	pending, err := r.store.XPENDING(key, v.Group)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: pending,
+	})
+}
+
+func (r *Server) handlerXCLAIM(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryXCLAIM
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	minIdle := time.Duration(v.MinIdle) * time.Millisecond
+	r.respondStreamEntries(ctx, func() ([]storage.StreamEntry, error) {
+		return r.store.XCLAIM(key, v.Group, v.Consumer, minIdle, v.IDs)
+	})
+}
+
+func (r *Server) respondStreamEntries(ctx *gin.Context, get func() ([]storage.StreamEntry, error)) {
+	res, err := get()
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res,
+	})
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EntryTSCREATE struct {
+	Retention int64 `json:"retention"`
+}
+
+type EntryTSADD struct {
+	Timestamp *int64  `json:"timestamp,omitempty"`
+	Value     float64 `json:"value"`
+}
+
+type EntryTSRANGE struct {
+	From        *int64 `json:"from,omitempty"`
+	To          *int64 `json:"to,omitempty"`
+	Aggregation string `json:"aggregation,omitempty"`
+	Bucket      int64  `json:"bucket,omitempty"`
+}
+
+type EntryTSRULE struct {
+	Src         string `json:"src"`
+	Dst         string `json:"dst"`
+	Aggregation string `json:"aggregation,omitempty"`
+	Bucket      int64  `json:"bucket,omitempty"`
+}
+
+func (r *Server) handlerTSCREATE(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryTSCREATE
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondInit(ctx, func() error {
+		return r.store.TSCREATE(key, v.Retention)
+	})
+}
+
+func (r *Server) handlerTSADD(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryTSADD
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	timestamp := time.Now().UnixMilli()
+	if v.Timestamp != nil {
+		timestamp = *v.Timestamp
+	}
+
+	res, err := r.store.TSADD(key, timestamp, v.Value)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res,
+	})
+}
+
+func (r *Server) handlerTSRANGE(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryTSRANGE
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	from, to := int64(0), int64(math.MaxInt64)
+	if v.From != nil {
+		from = *v.From
+	}
+	if v.To != nil {
+		to = *v.To
+	}
+
+	samples, err := r.store.TSRANGE(key, from, to, v.Aggregation, v.Bucket)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: samples,
+	})
+}
+
+func (r *Server) handlerTSCREATERULE(ctx *gin.Context) {
+	var v EntryTSRULE
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondInit(ctx, func() error {
+		return r.store.TSCREATERULE(v.Src, v.Dst, v.Aggregation, v.Bucket)
+	})
+}
+
+func (r *Server) handlerTSDELETERULE(ctx *gin.Context) {
+	var v EntryTSRULE
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondInit(ctx, func() error {
+		return r.store.TSDELETERULE(v.Src, v.Dst)
+	})
+}
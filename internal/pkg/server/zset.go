@@ -0,0 +1,237 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"golangProject/internal/pkg/storage"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EntryZADD struct {
+	Members []storage.ZMember `json:"members"`
+}
+
+type EntryZREM struct {
+	Members []string `json:"members"`
+}
+
+type EntryMember struct {
+	Member string `json:"member"`
+}
+
+type EntryZINCRBY struct {
+	Member string  `json:"member"`
+	Incr   float64 `json:"incr"`
+}
+
+type EntryZRANGE struct {
+	Start  int    `json:"start"`
+	Stop   int    `json:"stop"`
+	By     string `json:"by,omitempty"`
+	Min    string `json:"min,omitempty"`
+	Max    string `json:"max,omitempty"`
+	Rev    bool   `json:"rev,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+	Count  *int   `json:"count,omitempty"`
+}
+
+func (r *Server) handlerZADD(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryZADD
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.ZADD(key, v.Members)
+	})
+}
+
+func (r *Server) handlerZREM(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryZREM
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.ZREM(key, v.Members)
+	})
+}
+
+func (r *Server) handlerZCARD(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	r.respondCounter(ctx, func() (int, error) {
+		return r.store.ZCARD(key)
+	})
+}
+
+func (r *Server) handlerZSCORE(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryMember
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	score, err := r.store.ZSCORE(key, v.Member)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if score == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: *score,
+	})
+}
+
+func (r *Server) handlerZINCRBY(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	var v EntryZINCRBY
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	score, err := r.store.ZINCRBY(key, v.Member, v.Incr)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: score,
+	})
+}
+
+func (r *Server) handlerZRANK(ctx *gin.Context) {
+	r.respondRank(ctx, r.store.ZRANK)
+}
+
+func (r *Server) handlerZREVRANK(ctx *gin.Context) {
+	r.respondRank(ctx, r.store.ZREVRANK)
+}
+
+func (r *Server) respondRank(ctx *gin.Context, rank func(key string, member string) (*int, error)) {
+	key := ctx.Param("key")
+
+	var v EntryMember
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	res, err := rank(key, v.Member)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if res == nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: *res,
+	})
+}
+
+func (r *Server) handlerZRANGE(ctx *gin.Context) {
+	key := ctx.Param("key")
+
+	v := EntryZRANGE{Stop: -1}
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	count := -1
+	if v.Count != nil {
+		count = *v.Count
+	}
+
+	var (
+		res []storage.ZMember
+		err error
+	)
+	switch v.By {
+	case "":
+		res, err = r.store.ZRANGE(key, v.Start, v.Stop, v.Rev)
+	case "score":
+		res, err = r.store.ZRANGEBYSCORE(key, v.Min, v.Max, v.Rev, v.Offset, count)
+	case "lex":
+		res, err = r.store.ZRANGEBYLEX(key, v.Min, v.Max, v.Rev, v.Offset, count)
+	default:
+		err = errors.New("WrongArgs")
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res,
+	})
+}
+
+func (r *Server) handlerZPOPMIN(ctx *gin.Context) {
+	r.respondZPop(ctx, r.store.ZPOPMIN)
+}
+
+func (r *Server) handlerZPOPMAX(ctx *gin.Context) {
+	r.respondZPop(ctx, r.store.ZPOPMAX)
+}
+
+func (r *Server) respondZPop(ctx *gin.Context, pop func(key string, count int) ([]storage.ZMember, error)) {
+	key := ctx.Param("key")
+
+	var v EntryCount
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&v); err != nil && !errors.Is(err, io.EOF) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	count := 1
+	if v.Count != nil {
+		count = *v.Count
+	}
+
+	res, err := pop(key, count)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Entry{
+		Value: res,
+	})
+}
@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"math/bits"
+	"strings"
+)
+
+// maxBitOffset limits bitmaps to 512MB like Redis does.
+const maxBitOffset = 1<<32 - 1
+
+func getBit(data []byte, offset int) int {
+	if offset/8 >= len(data) {
+		return 0
+	}
+	return int(data[offset/8]>>(7-offset%8)) & 1
+}
+
+// bitRange converts a byte or bit range with negative positions counted
+// from the end to an inclusive range of bit offsets.
+func bitRange(data []byte, start int, end int, bitUnit bool) (int, int, bool) {
+	size := len(data)
+	if bitUnit {
+		size *= 8
+	}
+	start, end, ok := normalizeRange(start, end, size)
+	if !ok {
+		return 0, 0, false
+	}
+	if !bitUnit {
+		start, end = start*8, end*8+7
+	}
+	return start, end, true
+}
+
+func countBits(data []byte, lo int, hi int) int {
+	res := 0
+	for i := lo; i <= hi; {
+		if i%8 == 0 && i+7 <= hi {
+			res += bits.OnesCount8(data[i/8])
+			i += 8
+			continue
+		}
+		res += getBit(data, i)
+		i++
+	}
+	return res
+}
+
+// findBit returns offset of the first bit equal to bit in [lo, hi] or -1.
+func findBit(data []byte, bit int, lo int, hi int) int {
+	var skip byte
+	if bit == 0 {
+		skip = 0xff
+	}
+	for i := lo; i <= hi; {
+		if i%8 == 0 && i+7 <= hi && data[i/8] == skip {
+			i += 8
+			continue
+		}
+		if getBit(data, i) == bit {
+			return i
+		}
+		i++
+	}
+	return -1
+}
+
+func (r *Storage) getBitmap(key string) ([]byte, error) {
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindBitmap && struct_kind != kindNoStruct {
+		return nil, errors.New("KeyError: this key already exists and has different type")
+	}
+	return r.innerBitmap[key], nil
+}
+
+// SETBIT sets the bit at offset to bit, growing the bitmap with zero bits
+// if needed, and returns the previous bit. Bit 0 is the most significant
+// bit of the first byte.
+func (r *Storage) SETBIT(key string, offset int, bit int) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if offset < 0 || offset > maxBitOffset || (bit != 0 && bit != 1) {
+		return 0, errors.New("WrongArgs")
+	}
+
+	data, err := r.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+	if data == nil {
+		r.setKey(key, kindBitmap)
+		r.innerExpire[key] = 0
+	}
+	if need := offset/8 + 1; need > len(data) {
+		data = append(data, make([]byte, need-len(data))...)
+	}
+
+	old := getBit(data, offset)
+	mask := byte(1) << (7 - offset%8)
+	if bit == 1 {
+		data[offset/8] |= mask
+	} else {
+		data[offset/8] &^= mask
+	}
+	r.innerBitmap[key] = data
+	return old, nil
+}
+
+func (r *Storage) GETBIT(key string, offset int) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if offset < 0 {
+		return 0, errors.New("WrongArgs")
+	}
+
+	data, err := r.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+	return getBit(data, offset), nil
+}
+
+// BITCOUNT returns the number of set bits between start and end inclusive.
+// Positions are in bytes, or in bits if bitUnit is set.
+func (r *Storage) BITCOUNT(key string, start int, end int, bitUnit bool) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	data, err := r.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+	lo, hi, ok := bitRange(data, start, end, bitUnit)
+	if !ok {
+		return 0, nil
+	}
+	return countBits(data, lo, hi), nil
+}
+
+// BITPOS returns offset of the first bit equal to bit between start and end
+// or -1. Positions are in bytes, or in bits if bitUnit is set. If a clear
+// bit is searched without end and all bits are set, the offset right after
+// the bitmap is returned.
+func (r *Storage) BITPOS(key string, bit int, start int, end *int, bitUnit bool) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if bit != 0 && bit != 1 {
+		return 0, errors.New("WrongArgs")
+	}
+
+	data, err := r.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+	if data == nil {
+		if bit == 0 {
+			return 0, nil
+		}
+		return -1, nil
+	}
+
+	stop := -1
+	if end != nil {
+		stop = *end
+	}
+	lo, hi, ok := bitRange(data, start, stop, bitUnit)
+	if !ok {
+		return -1, nil
+	}
+	pos := findBit(data, bit, lo, hi)
+	if pos == -1 && bit == 0 && end == nil {
+		return len(data) * 8, nil
+	}
+	return pos, nil
+}
+
+// BITOP stores the result of op (AND, OR, XOR or NOT) over bitmaps by keys
+// in dst and returns its size in bytes. Shorter bitmaps are padded with zero
+// bytes. NOT takes exactly one key.
+func (r *Storage) BITOP(op string, dst string, keys []string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	op = strings.ToUpper(op)
+	if len(keys) == 0 || (op == "NOT" && len(keys) != 1) {
+		return 0, errors.New("WrongArgs")
+	}
+
+	var apply func(a, b byte) byte
+	switch op {
+	case "AND":
+		apply = func(a, b byte) byte { return a & b }
+	case "OR":
+		apply = func(a, b byte) byte { return a | b }
+	case "XOR":
+		apply = func(a, b byte) byte { return a ^ b }
+	case "NOT":
+	default:
+		return 0, errors.New("WrongArgs")
+	}
+
+	srcs := make([][]byte, 0, len(keys))
+	size := 0
+	for _, key := range keys {
+		data, err := r.getBitmap(key)
+		if err != nil {
+			return 0, err
+		}
+		srcs = append(srcs, data)
+		size = max(size, len(data))
+	}
+
+	res := make([]byte, size)
+	copy(res, srcs[0])
+	if op == "NOT" {
+		for i := range res {
+			res[i] = ^res[i]
+		}
+	}
+	for _, src := range srcs[1:] {
+		for i := range res {
+			var b byte
+			if i < len(src) {
+				b = src[i]
+			}
+			res[i] = apply(res[i], b)
+		}
+	}
+
+	if dstKind := r.getLiveStruct(dst); dstKind != kindNoStruct {
+		r.deleteKey(dst, dstKind)
+	}
+	if size == 0 {
+		return 0, nil
+	}
+	r.innerBitmap[dst] = res
+	r.setKey(dst, kindBitmap)
+	r.innerExpire[dst] = 0
+	return size, nil
+}
+
+// getBitmapState copies bitmaps because SETBIT changes them in place.
+func (r *Storage) getBitmapState() map[string][]byte {
+	res := make(map[string][]byte, len(r.innerBitmap))
+	for key, data := range r.innerBitmap {
+		res[key] = bytes.Clone(data)
+	}
+	return res
+}
+
+func (r *Storage) recoverBitmaps(state map[string][]byte) {
+	for key, data := range state {
+		if r.isExpired(key) {
+			delete(r.innerExpire, key)
+			continue
+		}
+		tempExp := r.innerExpire[key]
+		r.innerBitmap[key] = data
+		r.setKey(key, kindBitmap)
+		r.innerExpire[key] = tempExp
+	}
+}
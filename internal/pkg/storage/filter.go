@@ -0,0 +1,466 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"math/rand"
+	"slices"
+)
+
+const (
+	defaultFilterCapacity  = 1024
+	defaultFilterErrorRate = 0.01
+	cuckooBucketSize       = 4
+	cuckooMaxKicks         = 500
+	// maxFilterBits keeps each filter within 512MB like bitmaps.
+	maxFilterBits = maxBitOffset + 1
+)
+
+func checkFilterArgs(capacity int, errorRate float64) error {
+	if capacity <= 0 || capacity > maxFilterBits || !(errorRate > 0 && errorRate < 1) {
+		return errors.New("WrongArgs")
+	}
+	return nil
+}
+
+// bloomFilter answers whether an item was added with no false negatives
+// and about ErrorRate false positives while it holds at most Capacity items.
+type bloomFilter struct {
+	Bits      []byte  `json:"bits"`
+	Size      uint64  `json:"size"`
+	Hashes    int     `json:"hashes"`
+	Capacity  int     `json:"capacity"`
+	ErrorRate float64 `json:"errorrate"`
+}
+
+func newBloomFilter(capacity int, errorRate float64) (*bloomFilter, error) {
+	if err := checkFilterArgs(capacity, errorRate); err != nil {
+		return nil, err
+	}
+	bitsNeeded := math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+	if bitsNeeded > maxFilterBits {
+		return nil, errors.New("WrongArgs")
+	}
+	size := uint64(bitsNeeded)
+	hashes := max(int(math.Round(float64(size)/float64(capacity)*math.Ln2)), 1)
+	return &bloomFilter{
+		Bits:      make([]byte, (size+7)/8),
+		Size:      size,
+		Hashes:    hashes,
+		Capacity:  capacity,
+		ErrorRate: errorRate,
+	}, nil
+}
+
+// positions returns bit positions of item using double hashing.
+func (bf *bloomFilter) positions(item string) []uint64 {
+	h1 := hashElement(item)
+	h2 := mix64(h1+0x9e3779b97f4a7c15) | 1
+	res := make([]uint64, bf.Hashes)
+	for i := range res {
+		res[i] = (h1 + uint64(i)*h2) % bf.Size
+	}
+	return res
+}
+
+// add reports whether item was not in the filter before.
+func (bf *bloomFilter) add(item string) bool {
+	added := false
+	for _, pos := range bf.positions(item) {
+		if bf.Bits[pos/8]&(1<<(pos%8)) == 0 {
+			bf.Bits[pos/8] |= 1 << (pos % 8)
+			added = true
+		}
+	}
+	return added
+}
+
+func (bf *bloomFilter) exists(item string) bool {
+	for _, pos := range bf.positions(item) {
+		if bf.Bits[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// cuckooFilter keeps fingerprints of items in one of two candidate buckets,
+// which unlike bloomFilter makes deletion possible. Zero marks a free slot.
+type cuckooFilter struct {
+	slots      []uint32
+	numBuckets uint64
+	fpBits     int
+	capacity   int
+	errorRate  float64
+}
+
+func newCuckooFilter(capacity int, errorRate float64) (*cuckooFilter, error) {
+	if err := checkFilterArgs(capacity, errorRate); err != nil {
+		return nil, err
+	}
+	// With b slots per bucket a lookup checks 2b fingerprints,
+	// so the false positive rate is about 2b / 2^fpBits.
+	fpBits := int(math.Ceil(math.Log2(2 * cuckooBucketSize / errorRate)))
+	fpBits = min(max(fpBits, 4), 32)
+	numBuckets := uint64(1) << bits.Len64(uint64((capacity+cuckooBucketSize-1)/cuckooBucketSize-1))
+	if numBuckets*cuckooBucketSize*32 > maxFilterBits {
+		return nil, errors.New("WrongArgs")
+	}
+	return &cuckooFilter{
+		slots:      make([]uint32, numBuckets*cuckooBucketSize),
+		numBuckets: numBuckets,
+		fpBits:     fpBits,
+		capacity:   capacity,
+		errorRate:  errorRate,
+	}, nil
+}
+
+func (cf *cuckooFilter) locate(item string) (uint32, uint64, uint64) {
+	hash := hashElement(item)
+	fp := uint32(hash>>32) & (1<<cf.fpBits - 1)
+	if fp == 0 {
+		fp = 1
+	}
+	i1 := hash & (cf.numBuckets - 1)
+	return fp, i1, cf.altIndex(i1, fp)
+}
+
+// altIndex returns the other bucket of fingerprint fp stored in bucket i.
+// It is an involution, so it works for both buckets.
+func (cf *cuckooFilter) altIndex(i uint64, fp uint32) uint64 {
+	return (i ^ mix64(uint64(fp))) & (cf.numBuckets - 1)
+}
+
+func (cf *cuckooFilter) bucket(i uint64) []uint32 {
+	return cf.slots[i*cuckooBucketSize : (i+1)*cuckooBucketSize]
+}
+
+func (cf *cuckooFilter) put(i uint64, fp uint32) bool {
+	b := cf.bucket(i)
+	for j := range b {
+		if b[j] == 0 {
+			b[j] = fp
+			return true
+		}
+	}
+	return false
+}
+
+// add inserts item relocating other fingerprints if both buckets are full.
+// If no free slot is found, all relocations are undone and false is returned.
+func (cf *cuckooFilter) add(item string) bool {
+	fp, i1, i2 := cf.locate(item)
+	if cf.put(i1, fp) || cf.put(i2, fp) {
+		return true
+	}
+
+	type kick struct {
+		slot int
+		fp   uint32
+	}
+	path := make([]kick, 0, cuckooMaxKicks)
+	i := i1
+	if rand.Intn(2) == 0 {
+		i = i2
+	}
+	for n := 0; n < cuckooMaxKicks; n++ {
+		slot := int(i)*cuckooBucketSize + rand.Intn(cuckooBucketSize)
+		path = append(path, kick{slot: slot, fp: cf.slots[slot]})
+		fp, cf.slots[slot] = cf.slots[slot], fp
+		i = cf.altIndex(i, fp)
+		if cf.put(i, fp) {
+			return true
+		}
+	}
+
+	for j := len(path) - 1; j >= 0; j-- {
+		cf.slots[path[j].slot] = path[j].fp
+	}
+	return false
+}
+
+func (cf *cuckooFilter) exists(item string) bool {
+	fp, i1, i2 := cf.locate(item)
+	return slices.Contains(cf.bucket(i1), fp) || slices.Contains(cf.bucket(i2), fp)
+}
+
+func (cf *cuckooFilter) remove(item string) bool {
+	fp, i1, i2 := cf.locate(item)
+	for _, i := range []uint64{i1, i2} {
+		b := cf.bucket(i)
+		if j := slices.Index(b, fp); j != -1 {
+			b[j] = 0
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Storage) getBloom(key string) (*bloomFilter, error) {
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindBloom && struct_kind != kindNoStruct {
+		return nil, errors.New("KeyError: this key already exists and has different type")
+	}
+	return r.innerBloom[key], nil
+}
+
+func (r *Storage) getCuckoo(key string) (*cuckooFilter, error) {
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindCuckoo && struct_kind != kindNoStruct {
+		return nil, errors.New("KeyError: this key already exists and has different type")
+	}
+	return r.innerCuckoo[key], nil
+}
+
+func (r *Storage) setBloom(key string, bf *bloomFilter) {
+	r.innerBloom[key] = bf
+	r.setKey(key, kindBloom)
+	r.innerExpire[key] = 0
+}
+
+func (r *Storage) setCuckoo(key string, cf *cuckooFilter) {
+	r.innerCuckoo[key] = cf
+	r.setKey(key, kindCuckoo)
+	r.innerExpire[key] = 0
+}
+
+// BFRESERVE creates an empty Bloom filter for capacity items with
+// false positive rate errorRate.
+func (r *Storage) BFRESERVE(key string, capacity int, errorRate float64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.getLiveStruct(key) != kindNoStruct {
+		return errors.New("KeyError: this key already exists")
+	}
+	bf, err := newBloomFilter(capacity, errorRate)
+	if err != nil {
+		return err
+	}
+	r.setBloom(key, bf)
+	return nil
+}
+
+// BFADD adds item to the Bloom filter by key, creating it with default
+// capacity and error rate if needed. It returns false if item was
+// probably added before.
+func (r *Storage) BFADD(key string, item any) (bool, error) {
+	res, err := r.BFMADD(key, []any{item})
+	if err != nil {
+		return false, err
+	}
+	return res[0], nil
+}
+
+// BFMADD is the same as BFADD for several items.
+func (r *Storage) BFMADD(key string, items []any) ([]bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(items) == 0 {
+		return nil, errors.New("WrongArgs")
+	}
+	bf, err := r.getBloom(key)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := newValues(items)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, err
+	}
+
+	if bf == nil {
+		bf, _ = newBloomFilter(defaultFilterCapacity, defaultFilterErrorRate)
+		r.setBloom(key, bf)
+	}
+	res := make([]bool, 0, len(vals))
+	for _, val := range vals {
+		res = append(res, bf.add(scalarString(val)))
+	}
+	return res, nil
+}
+
+// BFEXISTS reports whether item was probably added to the Bloom filter.
+func (r *Storage) BFEXISTS(key string, item any) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	bf, err := r.getBloom(key)
+	if err != nil {
+		return false, err
+	}
+	val, err := newValue(item)
+	if err != nil {
+		return false, err
+	}
+	return bf != nil && bf.exists(scalarString(val)), nil
+}
+
+// CFRESERVE creates an empty cuckoo filter for capacity items with
+// false positive rate errorRate.
+func (r *Storage) CFRESERVE(key string, capacity int, errorRate float64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.getLiveStruct(key) != kindNoStruct {
+		return errors.New("KeyError: this key already exists")
+	}
+	cf, err := newCuckooFilter(capacity, errorRate)
+	if err != nil {
+		return err
+	}
+	r.setCuckoo(key, cf)
+	return nil
+}
+
+// CFADD adds item to the cuckoo filter by key, creating it with default
+// capacity and error rate if needed. An item added several times has to be
+// deleted as many times.
+func (r *Storage) CFADD(key string, item any) error {
+	_, err := r.cfadd(key, item, false)
+	return err
+}
+
+// CFADDNX adds item to the cuckoo filter only if it probably is not there
+// and reports whether it was added.
+func (r *Storage) CFADDNX(key string, item any) (bool, error) {
+	return r.cfadd(key, item, true)
+}
+
+func (r *Storage) cfadd(key string, item any, nx bool) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cf, err := r.getCuckoo(key)
+	if err != nil {
+		return false, err
+	}
+	val, err := newValue(item)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, err
+	}
+
+	if cf == nil {
+		cf, _ = newCuckooFilter(defaultFilterCapacity, defaultFilterErrorRate)
+		r.setCuckoo(key, cf)
+	}
+	if nx && cf.exists(scalarString(val)) {
+		return false, nil
+	}
+	if !cf.add(scalarString(val)) {
+		return false, errors.New("ValueError: filter is full")
+	}
+	return true, nil
+}
+
+// CFEXISTS reports whether item is probably in the cuckoo filter.
+func (r *Storage) CFEXISTS(key string, item any) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cf, err := r.getCuckoo(key)
+	if err != nil {
+		return false, err
+	}
+	val, err := newValue(item)
+	if err != nil {
+		return false, err
+	}
+	return cf != nil && cf.exists(scalarString(val)), nil
+}
+
+// CFDEL deletes one occurrence of item from the cuckoo filter and reports
+// whether it was found. Deleting an item that was never added may remove
+// another item with the same fingerprint.
+func (r *Storage) CFDEL(key string, item any) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cf, err := r.getCuckoo(key)
+	if err != nil || cf == nil {
+		return false, err
+	}
+	val, err := newValue(item)
+	if err != nil {
+		return false, err
+	}
+	return cf.remove(scalarString(val)), nil
+}
+
+// cuckooState keeps fingerprints packed as little-endian uint32.
+type cuckooState struct {
+	Slots     []byte  `json:"slots"`
+	FpBits    int     `json:"fpbits"`
+	Capacity  int     `json:"capacity"`
+	ErrorRate float64 `json:"errorrate"`
+}
+
+func (r *Storage) getBloomState() map[string]bloomFilter {
+	res := make(map[string]bloomFilter, len(r.innerBloom))
+	for key, bf := range r.innerBloom {
+		state := *bf
+		state.Bits = bytes.Clone(bf.Bits)
+		res[key] = state
+	}
+	return res
+}
+
+func (r *Storage) getCuckooState() map[string]cuckooState {
+	res := make(map[string]cuckooState, len(r.innerCuckoo))
+	for key, cf := range r.innerCuckoo {
+		slots := make([]byte, 0, 4*len(cf.slots))
+		for _, fp := range cf.slots {
+			slots = binary.LittleEndian.AppendUint32(slots, fp)
+		}
+		res[key] = cuckooState{
+			Slots:     slots,
+			FpBits:    cf.fpBits,
+			Capacity:  cf.capacity,
+			ErrorRate: cf.errorRate,
+		}
+	}
+	return res
+}
+
+func (r *Storage) recoverFilters(bloomState map[string]bloomFilter, cuckooState map[string]cuckooState) {
+	for key, bf := range bloomState {
+		if r.isExpired(key) {
+			delete(r.innerExpire, key)
+			continue
+		}
+		if bf.Size == 0 || bf.Size > maxFilterBits || bf.Hashes <= 0 || uint64(len(bf.Bits)) != (bf.Size+7)/8 {
+			r.logger.Error("Bloom filter " + key + " has wrong size")
+			continue
+		}
+		tempExp := r.innerExpire[key]
+		r.setBloom(key, &bf)
+		r.innerExpire[key] = tempExp
+	}
+
+	for key, inCuckoo := range cuckooState {
+		if r.isExpired(key) {
+			delete(r.innerExpire, key)
+			continue
+		}
+		cf, err := newCuckooFilter(inCuckoo.Capacity, inCuckoo.ErrorRate)
+		if err != nil {
+			r.logger.Error("Cuckoo filter " + key + " has wrong parameters")
+			continue
+		}
+		if len(inCuckoo.Slots) != 4*len(cf.slots) || inCuckoo.FpBits != cf.fpBits {
+			r.logger.Error("Cuckoo filter " + key + " has wrong size")
+			continue
+		}
+		for i := range cf.slots {
+			cf.slots[i] = binary.LittleEndian.Uint32(inCuckoo.Slots[4*i:])
+		}
+		tempExp := r.innerExpire[key]
+		r.setCuckoo(key, cf)
+		r.innerExpire[key] = tempExp
+	}
+}
@@ -153,9 +153,6 @@ func (r *Storage) ReadStateFromDB() error {
 }
 
 func (r *Storage) WriteStateToDB() error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
 	state := r.getState()
 	encodedState, err := json.Marshal(state)
 	if err != nil {
@@ -0,0 +1,377 @@
+package storage
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	geoStep      = 26
+	geoLatLimit  = 85.05112878
+	geoLonLimit  = 180
+	earthRadiusM = 6372797.560856
+)
+
+type GeoPoint struct {
+	Longitude float64 `json:"longitude"`
+	Latitude  float64 `json:"latitude"`
+}
+
+type GeoMember struct {
+	Member string `json:"member"`
+	GeoPoint
+}
+
+type GeoResult struct {
+	Member   string  `json:"member"`
+	Distance float64 `json:"distance"`
+	GeoPoint
+}
+
+// GeoQuery describes GEOSEARCH: the center is either FromMember or
+// FromPoint, and the area is either a circle of Radius or a box of Width
+// and Height. Distances are in Unit. Count of zero means no limit.
+type GeoQuery struct {
+	FromMember string
+	FromPoint  *GeoPoint
+	Radius     float64
+	Width      float64
+	Height     float64
+	Unit       string
+	Desc       bool
+	Count      int
+}
+
+// geoUnit returns the number of meters in unit.
+func geoUnit(unit string) (float64, error) {
+	switch strings.ToLower(unit) {
+	case "", "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "mi":
+		return 1609.34, nil
+	case "ft":
+		return 0.3048, nil
+	}
+	return 0, errors.New("ValueError: unsupported unit, use m, km, mi or ft")
+}
+
+func validGeoPoint(p GeoPoint) bool {
+	return math.Abs(p.Longitude) <= geoLonLimit && math.Abs(p.Latitude) <= geoLatLimit
+}
+
+// geoCell returns the index of the cell containing val when [-limit, limit]
+// is split into 2^step cells.
+func geoCell(val float64, limit float64, step int) uint64 {
+	cells := uint64(1) << step
+	idx := uint64((val + limit) / (2 * limit) * float64(cells))
+	return min(idx, cells-1)
+}
+
+// interleave spreads bits of lat over even and bits of lon over odd
+// positions of the result.
+func interleave(lat uint64, lon uint64) uint64 {
+	var res uint64
+	for i := 0; i < geoStep; i++ {
+		res |= (lat>>i&1)<<(2*i) | (lon>>i&1)<<(2*i+1)
+	}
+	return res
+}
+
+func deinterleave(hash uint64) (uint64, uint64) {
+	var lat, lon uint64
+	for i := 0; i < geoStep; i++ {
+		lat |= (hash >> (2 * i) & 1) << i
+		lon |= (hash >> (2*i + 1) & 1) << i
+	}
+	return lat, lon
+}
+
+// geohashScore encodes a point as a 52-bit geohash, which a float64
+// score keeps exactly, so that nearby points get close scores.
+func geohashScore(p GeoPoint) float64 {
+	lat := geoCell(p.Latitude, geoLatLimit, geoStep)
+	lon := geoCell(p.Longitude, geoLonLimit, geoStep)
+	return float64(interleave(lat, lon))
+}
+
+// geohashPoint returns the center of the geohash cell of score.
+func geohashPoint(score float64) (GeoPoint, bool) {
+	if !(score >= 0 && score < 1<<(2*geoStep)) {
+		return GeoPoint{}, false
+	}
+	lat, lon := deinterleave(uint64(score))
+	cells := float64(uint64(1) << geoStep)
+	return GeoPoint{
+		Longitude: (float64(lon)+0.5)/cells*2*geoLonLimit - geoLonLimit,
+		Latitude:  (float64(lat)+0.5)/cells*2*geoLatLimit - geoLatLimit,
+	}, true
+}
+
+// geoDistance returns the haversine distance in meters.
+func geoDistance(a GeoPoint, b GeoPoint) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	u := math.Sin((lat2 - lat1) / 2)
+	v := math.Sin((b.Longitude - a.Longitude) * math.Pi / 180 / 2)
+	h := u*u + math.Cos(lat1)*math.Cos(lat2)*v*v
+	return 2 * earthRadiusM * math.Asin(math.Sqrt(min(h, 1)))
+}
+
+// geoArea checks points against a search area given in meters.
+type geoArea struct {
+	center GeoPoint
+	radius float64
+	width  float64
+	height float64
+}
+
+// distance returns the distance from the center to p or false if p lies
+// outside the area. A box is measured along the meridian and along the
+// parallel of p.
+func (a geoArea) distance(p GeoPoint) (float64, bool) {
+	dist := geoDistance(a.center, p)
+	if a.radius > 0 {
+		return dist, dist <= a.radius
+	}
+	latDist := geoDistance(a.center, GeoPoint{Longitude: a.center.Longitude, Latitude: p.Latitude})
+	lonDist := geoDistance(GeoPoint{Longitude: a.center.Longitude, Latitude: p.Latitude}, p)
+	return dist, latDist <= a.height/2 && lonDist <= a.width/2
+}
+
+// bounds returns the latitude and longitude ranges covering the area.
+// The longitude range may go beyond ±180.
+func (a geoArea) bounds() (float64, float64, float64, float64) {
+	lat := a.center.Latitude * math.Pi / 180
+	var latDelta, sinLon float64
+	if a.radius > 0 {
+		// The circle is widest at its tangent meridians.
+		latDelta = a.radius / earthRadiusM
+		sinLon = math.Sin(latDelta) / math.Cos(lat)
+		if math.Abs(lat)+latDelta >= math.Pi/2 {
+			sinLon = 1
+		}
+	} else {
+		// A box is widest on its parallel farthest from the equator, where
+		// points are compared by great-circle distance along the parallel.
+		latDelta = a.height / 2 / earthRadiusM
+		farthest := min(math.Abs(lat)+latDelta, math.Pi/2)
+		sinLon = math.Sin(min(a.width/2/earthRadiusM, math.Pi/2)) / math.Cos(farthest)
+	}
+
+	latDelta = latDelta * 180 / math.Pi
+	latMin, latMax := a.center.Latitude-latDelta, a.center.Latitude+latDelta
+	if !(sinLon < 1) {
+		return latMin, latMax, -geoLonLimit, geoLonLimit
+	}
+	lonDelta := math.Asin(sinLon) * 180 / math.Pi
+	if a.radius == 0 {
+		lonDelta *= 2
+	}
+	return latMin, latMax, a.center.Longitude - lonDelta, a.center.Longitude + lonDelta
+}
+
+// cellRange is an inclusive range of cell indexes.
+type cellRange struct {
+	lo uint64
+	hi uint64
+}
+
+// lonCells returns cells covering [lo, hi] split in two ranges if it
+// crosses the antimeridian.
+func lonCells(lo float64, hi float64, step int) []cellRange {
+	if hi-lo >= 2*geoLonLimit {
+		return []cellRange{{0, uint64(1)<<step - 1}}
+	}
+	if lo < -geoLonLimit {
+		lo += 2 * geoLonLimit
+		hi += 2 * geoLonLimit
+	}
+	if hi > geoLonLimit {
+		first := cellRange{geoCell(lo, geoLonLimit, step), uint64(1)<<step - 1}
+		second := cellRange{0, geoCell(hi-2*geoLonLimit, geoLonLimit, step)}
+		if second.hi >= first.lo {
+			return []cellRange{{0, uint64(1)<<step - 1}}
+		}
+		return []cellRange{first, second}
+	}
+	return []cellRange{{geoCell(lo, geoLonLimit, step), geoCell(hi, geoLonLimit, step)}}
+}
+
+// scoreRanges returns ranges of geohash scores, the upper limit excluded,
+// of at most 9 cells that cover the area like Redis does.
+func (a geoArea) scoreRanges() [][2]float64 {
+	latMin, latMax, lonMin, lonMax := a.bounds()
+	latMin, latMax = max(latMin, -geoLatLimit), min(latMax, geoLatLimit)
+
+	step := geoStep
+	var lats cellRange
+	var lons []cellRange
+	for ; step > 0; step-- {
+		lats = cellRange{geoCell(latMin, geoLatLimit, step), geoCell(latMax, geoLatLimit, step)}
+		lons = lonCells(lonMin, lonMax, step)
+		count := uint64(0)
+		for _, lon := range lons {
+			count += lon.hi - lon.lo + 1
+		}
+		if count*(lats.hi-lats.lo+1) <= 9 {
+			break
+		}
+	}
+
+	shift := 2 * (geoStep - step)
+	res := make([][2]float64, 0, 9)
+	for lat := lats.lo; lat <= lats.hi; lat++ {
+		for _, lon := range lons {
+			for i := lon.lo; i <= lon.hi; i++ {
+				hash := interleave(lat, i)
+				res = append(res, [2]float64{float64(hash << shift), float64((hash + 1) << shift)})
+			}
+		}
+	}
+	return res
+}
+
+// GEOADD adds members with their positions to the sorted set by key
+// and returns how many of them were new. Positions are kept as geohash
+// scores, so the sorted set can be read with ZSET commands as well.
+func (r *Storage) GEOADD(key string, members []GeoMember) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	zmembers := make([]ZMember, 0, len(members))
+	for _, m := range members {
+		if !validGeoPoint(m.GeoPoint) {
+			return 0, errors.New("ValueError: invalid longitude,latitude pair")
+		}
+		zmembers = append(zmembers, ZMember{Member: m.Member, Score: geohashScore(m.GeoPoint)})
+	}
+	return r.zadd(key, zmembers)
+}
+
+// GEOPOS returns positions of members or nil for missing members.
+func (r *Storage) GEOPOS(key string, members []string) ([]*GeoPoint, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	zs, err := r.getZSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*GeoPoint, 0, len(members))
+	for _, member := range members {
+		res = append(res, r.geoPos(zs, member))
+	}
+	return res, nil
+}
+
+func (r *Storage) geoPos(zs *sortedSet, member string) *GeoPoint {
+	if zs == nil {
+		return nil
+	}
+	score, ok := zs.scores[member]
+	if !ok {
+		return nil
+	}
+	p, ok := geohashPoint(score)
+	if !ok {
+		return nil
+	}
+	return &p
+}
+
+// GEODIST returns the distance between two members in unit or nil
+// if any of them is missing.
+func (r *Storage) GEODIST(key string, member1 string, member2 string, unit string) (*float64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	meters, err := geoUnit(unit)
+	if err != nil {
+		return nil, err
+	}
+	zs, err := r.getZSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	p1, p2 := r.geoPos(zs, member1), r.geoPos(zs, member2)
+	if p1 == nil || p2 == nil {
+		return nil, nil
+	}
+	dist := geoDistance(*p1, *p2) / meters
+	return &dist, nil
+}
+
+// GEOSEARCH returns members inside the area described by query ordered
+// by distance from its center.
+func (r *Storage) GEOSEARCH(key string, query GeoQuery) ([]GeoResult, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	meters, err := geoUnit(query.Unit)
+	if err != nil {
+		return nil, err
+	}
+	isCircle := query.Radius > 0 && query.Width == 0 && query.Height == 0
+	isBox := query.Radius == 0 && query.Width > 0 && query.Height > 0
+	hasCenter := (query.FromMember != "") != (query.FromPoint != nil)
+	if !hasCenter || isCircle == isBox || query.Count < 0 {
+		return nil, errors.New("WrongArgs")
+	}
+
+	zs, err := r.getZSet(key)
+	if err != nil {
+		return nil, err
+	}
+	area := geoArea{
+		radius: query.Radius * meters,
+		width:  query.Width * meters,
+		height: query.Height * meters,
+	}
+	if query.FromPoint != nil {
+		if !validGeoPoint(*query.FromPoint) {
+			return nil, errors.New("ValueError: invalid longitude,latitude pair")
+		}
+		area.center = *query.FromPoint
+	} else {
+		center := r.geoPos(zs, query.FromMember)
+		if center == nil {
+			return nil, errors.New("KeyError")
+		}
+		area.center = *center
+	}
+	if zs == nil {
+		return []GeoResult{}, nil
+	}
+
+	res := make([]GeoResult, 0)
+	for _, scores := range area.scoreRanges() {
+		lo := zs.order.CountWhile(func(m ZMember) bool { return m.Score < scores[0] })
+		hi := zs.order.CountWhile(func(m ZMember) bool { return m.Score < scores[1] })
+		for _, m := range zs.order.Slice(lo, hi-1) {
+			p, _ := geohashPoint(m.Score)
+			if dist, ok := area.distance(p); ok {
+				res = append(res, GeoResult{Member: m.Member, Distance: dist / meters, GeoPoint: p})
+			}
+		}
+	}
+
+	slices.SortFunc(res, func(a, b GeoResult) int {
+		if query.Desc {
+			a, b = b, a
+		}
+		if a.Distance != b.Distance {
+			return cmp.Compare(a.Distance, b.Distance)
+		}
+		return strings.Compare(a.Member, b.Member)
+	})
+	if query.Count > 0 && len(res) > query.Count {
+		res = res[:query.Count]
+	}
+	return res, nil
+}
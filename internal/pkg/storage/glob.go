@@ -0,0 +1,90 @@
+package storage
+
+// matchPattern reports whether str matches the glob-style pattern.
+// Supported syntax: * (any sequence), ? (any single character),
+// [abc], [^abc], [a-z] (character classes) and \x (escape).
+func matchPattern(pattern, str string) bool {
+	p, s := []rune(pattern), []rune(str)
+	pi, si := 0, 0
+	starP, starS := -1, 0
+
+	for si < len(s) {
+		if pi < len(p) {
+			switch p[pi] {
+			case '*':
+				starP, starS = pi, si
+				pi++
+				continue
+			case '?':
+				pi++
+				si++
+				continue
+			case '[':
+				if end, ok := matchClass(p, pi, s[si]); ok {
+					pi = end
+					si++
+					continue
+				}
+			case '\\':
+				if pi+1 < len(p) && p[pi+1] == s[si] {
+					pi += 2
+					si++
+					continue
+				}
+			default:
+				if p[pi] == s[si] {
+					pi++
+					si++
+					continue
+				}
+			}
+		}
+		if starP == -1 {
+			return false
+		}
+		starS++
+		pi, si = starP+1, starS
+	}
+
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// matchClass matches ch against the character class starting at p[start] == '['.
+// It returns the index right after the closing bracket and whether ch matched.
+func matchClass(p []rune, start int, ch rune) (int, bool) {
+	i := start + 1
+	negate := false
+	if i < len(p) && p[i] == '^' {
+		negate = true
+		i++
+	}
+
+	matched := false
+	for i < len(p) && p[i] != ']' {
+		lo := p[i]
+		if lo == '\\' && i+1 < len(p) {
+			i++
+			lo = p[i]
+		}
+		hi := lo
+		if i+2 < len(p) && p[i+1] == '-' && p[i+2] != ']' {
+			hi = p[i+2]
+			i += 2
+		}
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if lo <= ch && ch <= hi {
+			matched = true
+		}
+		i++
+	}
+	if i >= len(p) {
+		return start, false
+	}
+
+	return i + 1, matched != negate
+}
@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	hllPrecision = 14
+	hllRegisters = 1 << hllPrecision
+	hllBits      = 6
+	hllMask      = 1<<hllBits - 1
+	// hllSize is 12KB of packed 6-bit registers and a padding byte,
+	// so that every register can be read as two adjacent bytes.
+	hllSize = hllRegisters*hllBits/8 + 1
+)
+
+// hyperLogLog estimates the number of distinct elements with registers
+// keeping the maximal number of trailing zeros seen in element hashes.
+type hyperLogLog []byte
+
+func newHyperLogLog() hyperLogLog {
+	return make(hyperLogLog, hllSize)
+}
+
+func (h hyperLogLog) get(i int) uint8 {
+	pos := i * hllBits
+	b, shift := pos/8, pos%8
+	return uint8((uint16(h[b])|uint16(h[b+1])<<8)>>shift) & hllMask
+}
+
+func (h hyperLogLog) set(i int, val uint8) {
+	pos := i * hllBits
+	b, shift := pos/8, pos%8
+	word := uint16(h[b]) | uint16(h[b+1])<<8
+	word = word&^(hllMask<<shift) | uint16(val)<<shift
+	h[b], h[b+1] = byte(word), byte(word>>8)
+}
+
+func hashElement(elem string) uint64 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(elem))
+	// fnv spreads short keys poorly, so the hash is mixed further.
+	return mix64(hasher.Sum64())
+}
+
+// mix64 is the splitmix64 finalizer.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// add reports whether a register was updated.
+func (h hyperLogLog) add(elem string) bool {
+	hash := hashElement(elem)
+	i := int(hash & (hllRegisters - 1))
+	rest := hash>>hllPrecision | 1<<(64-hllPrecision)
+	count := uint8(bits.TrailingZeros64(rest) + 1)
+	if count > h.get(i) {
+		h.set(i, count)
+		return true
+	}
+	return false
+}
+
+func (h hyperLogLog) merge(other hyperLogLog) {
+	for i := 0; i < hllRegisters; i++ {
+		if val := other.get(i); val > h.get(i) {
+			h.set(i, val)
+		}
+	}
+}
+
+func (h hyperLogLog) count() int {
+	sum := 0.0
+	zeros := 0
+	for i := 0; i < hllRegisters; i++ {
+		val := h.get(i)
+		if val == 0 {
+			zeros++
+		}
+		sum += math.Ldexp(1, -int(val))
+	}
+
+	m := float64(hllRegisters)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(math.Round(estimate))
+}
+
+func (r *Storage) getHLL(key string) (hyperLogLog, error) {
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindHLL && struct_kind != kindNoStruct {
+		return nil, errors.New("KeyError: this key already exists and has different type")
+	}
+	return r.innerHLL[key], nil
+}
+
+func (r *Storage) setHLL(key string, h hyperLogLog) {
+	r.innerHLL[key] = h
+	r.setKey(key, kindHLL)
+	r.innerExpire[key] = 0
+}
+
+// PFADD adds elements to the HyperLogLog by key. It returns true if
+// the estimated cardinality may have changed.
+func (r *Storage) PFADD(key string, elements []any) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	h, err := r.getHLL(key)
+	if err != nil {
+		return false, err
+	}
+	vals, err := newValues(elements)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, err
+	}
+
+	changed := false
+	if h == nil {
+		h = newHyperLogLog()
+		r.setHLL(key, h)
+		changed = true
+	}
+	for _, val := range vals {
+		if h.add(scalarString(val)) {
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// PFCOUNT returns the estimated number of distinct elements added
+// to the union of HyperLogLogs by keys.
+func (r *Storage) PFCOUNT(keys []string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(keys) == 0 {
+		return 0, errors.New("WrongArgs")
+	}
+
+	union, err := r.unionHLL(keys)
+	if err != nil {
+		return 0, err
+	}
+	return union.count(), nil
+}
+
+// PFMERGE stores the union of dst and HyperLogLogs by keys in dst.
+func (r *Storage) PFMERGE(dst string, keys []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	union, err := r.unionHLL(append([]string{dst}, keys...))
+	if err != nil {
+		return err
+	}
+	// An existing dst keeps its time to live.
+	if _, ok := r.innerHLL[dst]; ok {
+		r.innerHLL[dst] = union
+		return nil
+	}
+	r.setHLL(dst, union)
+	return nil
+}
+
+func (r *Storage) unionHLL(keys []string) (hyperLogLog, error) {
+	res := newHyperLogLog()
+	for _, key := range keys {
+		h, err := r.getHLL(key)
+		if err != nil {
+			return nil, err
+		}
+		if h != nil {
+			res.merge(h)
+		}
+	}
+	return res, nil
+}
+
+// hllState keeps either packed registers or, for HyperLogLogs with
+// few non-zero registers, triples of register index and value.
+type hllState struct {
+	Dense  []byte `json:"dense,omitempty"`
+	Sparse []byte `json:"sparse,omitempty"`
+}
+
+func (r *Storage) getHLLState() map[string]hllState {
+	res := make(map[string]hllState, len(r.innerHLL))
+	for key, h := range r.innerHLL {
+		sparse := make([]byte, 0)
+		for i := 0; i < hllRegisters && len(sparse) < hllSize; i++ {
+			if val := h.get(i); val != 0 {
+				sparse = append(sparse, byte(i>>8), byte(i), val)
+			}
+		}
+		if len(sparse) < hllSize {
+			res[key] = hllState{Sparse: sparse}
+		} else {
+			res[key] = hllState{Dense: append(hyperLogLog(nil), h...)}
+		}
+	}
+	return res
+}
+
+func (r *Storage) recoverHLLs(state map[string]hllState) {
+	for key, inHLL := range state {
+		if r.isExpired(key) {
+			delete(r.innerExpire, key)
+			continue
+		}
+		h := newHyperLogLog()
+		if inHLL.Dense != nil {
+			if len(inHLL.Dense) != hllSize {
+				r.logger.Error("HyperLogLog " + key + " has wrong size")
+				continue
+			}
+			copy(h, inHLL.Dense)
+		}
+		for i := 0; i+2 < len(inHLL.Sparse); i += 3 {
+			idx := int(inHLL.Sparse[i])<<8 | int(inHLL.Sparse[i+1])
+			h.set(idx%hllRegisters, inHLL.Sparse[i+2]&hllMask)
+		}
+
+		tempExp := r.innerExpire[key]
+		r.setHLL(key, h)
+		r.innerExpire[key] = tempExp
+	}
+}
@@ -0,0 +1,189 @@
+package storage
+
+import "math/rand"
+
+type orderedNode[K any] struct {
+	key   K
+	prior int
+	size  int
+	left  *orderedNode[K]
+	right *orderedNode[K]
+}
+
+// orderedTreap is a treap keyed by K, ordered with less. Unlike Treap,
+// which is indexed implicitly by position, it keeps its keys sorted and unique.
+type orderedTreap[K any] struct {
+	root *orderedNode[K]
+	less func(a, b K) bool
+}
+
+func newOrderedTreap[K any](less func(a, b K) bool) *orderedTreap[K] {
+	return &orderedTreap[K]{
+		root: nil,
+		less: less,
+	}
+}
+
+func getOrderedSize[K any](n *orderedNode[K]) int {
+	if n != nil {
+		return n.size
+	}
+	return 0
+}
+
+func updateOrdered[K any](n *orderedNode[K]) {
+	if n != nil {
+		n.size = getOrderedSize(n.left) + 1 + getOrderedSize(n.right)
+	}
+}
+
+func mergeOrdered[K any](a, b *orderedNode[K]) *orderedNode[K] {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.prior > b.prior {
+		a.right = mergeOrdered(a.right, b)
+		updateOrdered(a)
+		return a
+	} else {
+		b.left = mergeOrdered(a, b.left)
+		updateOrdered(b)
+		return b
+	}
+}
+
+// splitOrdered splits n into keys that go before k and the rest.
+// If inclusive is set, k itself goes to the left part.
+func (trp *orderedTreap[K]) splitOrdered(n *orderedNode[K], k K, inclusive bool) (*orderedNode[K], *orderedNode[K]) {
+	if n == nil {
+		return nil, nil
+	}
+	goesLeft := trp.less(n.key, k)
+	if inclusive {
+		goesLeft = !trp.less(k, n.key)
+	}
+	if goesLeft {
+		a, b := trp.splitOrdered(n.right, k, inclusive)
+		n.right = a
+		updateOrdered(n)
+		return n, b
+	} else {
+		a, b := trp.splitOrdered(n.left, k, inclusive)
+		n.left = b
+		updateOrdered(n)
+		return a, n
+	}
+}
+
+func (trp *orderedTreap[K]) Contains(k K) bool {
+	_, ok := trp.Find(k)
+	return ok
+}
+
+// Find returns the stored key equal to k. It is useful when less
+// compares only a part of K.
+func (trp *orderedTreap[K]) Find(k K) (K, bool) {
+	n := trp.root
+	for n != nil {
+		switch {
+		case trp.less(k, n.key):
+			n = n.left
+		case trp.less(n.key, k):
+			n = n.right
+		default:
+			return n.key, true
+		}
+	}
+	var zero K
+	return zero, false
+}
+
+func (trp *orderedTreap[K]) Insert(k K) bool {
+	if trp.Contains(k) {
+		return false
+	}
+	newNode := &orderedNode[K]{
+		key:   k,
+		prior: rand.Int(),
+		size:  1,
+	}
+	less, greater := trp.splitOrdered(trp.root, k, false)
+	trp.root = mergeOrdered(mergeOrdered(less, newNode), greater)
+	return true
+}
+
+func (trp *orderedTreap[K]) Delete(k K) bool {
+	less, greater := trp.splitOrdered(trp.root, k, false)
+	equal, greater := trp.splitOrdered(greater, k, true)
+	trp.root = mergeOrdered(less, greater)
+	return equal != nil
+}
+
+func (trp *orderedTreap[K]) Len() int {
+	return getOrderedSize(trp.root)
+}
+
+// AscendAfter calls fn for every key greater than after in ascending order
+// until fn returns false. A nil after starts from the smallest key.
+func (trp *orderedTreap[K]) AscendAfter(after *K, fn func(K) bool) {
+	trp.ascend(trp.root, after, fn)
+}
+
+func (trp *orderedTreap[K]) ascend(n *orderedNode[K], after *K, fn func(K) bool) bool {
+	if n == nil {
+		return true
+	}
+	if after != nil && !trp.less(*after, n.key) {
+		return trp.ascend(n.right, after, fn)
+	}
+	if !trp.ascend(n.left, after, fn) {
+		return false
+	}
+	if !fn(n.key) {
+		return false
+	}
+	return trp.ascend(n.right, nil, fn)
+}
+
+// CountWhile returns the number of smallest keys for which pred holds.
+// pred must hold for a prefix of the keys in ascending order.
+func (trp *orderedTreap[K]) CountWhile(pred func(K) bool) int {
+	res := 0
+	n := trp.root
+	for n != nil {
+		if pred(n.key) {
+			res += getOrderedSize(n.left) + 1
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return res
+}
+
+// Slice returns keys with positions from start to stop inclusive
+// in ascending order.
+func (trp *orderedTreap[K]) Slice(start, stop int) []K {
+	res := make([]K, 0, max(stop-start+1, 0))
+	trp.slice(trp.root, start, stop, &res)
+	return res
+}
+
+func (trp *orderedTreap[K]) slice(n *orderedNode[K], start, stop int, res *[]K) {
+	if n == nil || start > stop {
+		return
+	}
+	leftSize := getOrderedSize(n.left)
+	if start < leftSize {
+		trp.slice(n.left, start, min(stop, leftSize-1), res)
+	}
+	if start <= leftSize && leftSize <= stop {
+		*res = append(*res, n.key)
+	}
+	if stop > leftSize {
+		trp.slice(n.right, max(start-leftSize-1, 0), stop-leftSize-1, res)
+	}
+}
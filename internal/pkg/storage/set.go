@@ -0,0 +1,391 @@
+package storage
+
+import (
+	"cmp"
+	"errors"
+	"math/rand"
+	"slices"
+	"strings"
+)
+
+// maxRandomRepeats limits how many repeating members SRANDMEMBER returns.
+const maxRandomRepeats = 1 << 20
+
+type valueSet map[value]struct{}
+
+// compareValues orders integers before strings, integers by value
+// and strings lexicographically.
+func compareValues(a, b value) int {
+	if a.Kin != b.Kin {
+		if a.Kin == kindInt {
+			return -1
+		}
+		return 1
+	}
+	if a.Kin == kindInt {
+		return cmp.Compare(toInt(a.Val), toInt(b.Val))
+	}
+	return strings.Compare(a.Val.(string), b.Val.(string))
+}
+
+func (st valueSet) sortedValues() []any {
+	vals := make([]value, 0, len(st))
+	for val := range st {
+		vals = append(vals, val)
+	}
+	slices.SortFunc(vals, compareValues)
+
+	res := make([]any, 0, len(vals))
+	for _, val := range vals {
+		res = append(res, val.Val)
+	}
+	return res
+}
+
+func newValues(args []any) ([]value, error) {
+	res := make([]value, 0, len(args))
+	for _, arg := range args {
+		val, err := newValue(arg)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, val)
+	}
+	return res, nil
+}
+
+func (r *Storage) getSet(key string) (valueSet, error) {
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindSet && struct_kind != kindNoStruct {
+		return nil, errors.New("KeyError: this key already exists and has different type")
+	}
+	return r.innerSet[key], nil
+}
+
+// SADD adds members to the set by key and returns how many of them were new.
+func (r *Storage) SADD(key string, members []any) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.sadd(key, members)
+}
+
+func (r *Storage) sadd(key string, members []any) (int, error) {
+	if len(members) == 0 {
+		return 0, errors.New("WrongArgs")
+	}
+
+	st, err := r.getSet(key)
+	if err != nil {
+		return 0, err
+	}
+	vals, err := newValues(members)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return 0, err
+	}
+
+	if st == nil {
+		st = make(valueSet)
+		r.innerSet[key] = st
+		r.setKey(key, kindSet)
+		r.innerExpire[key] = 0
+	}
+
+	added := 0
+	for _, val := range vals {
+		if _, ok := st[val]; !ok {
+			st[val] = struct{}{}
+			added++
+		}
+	}
+	return added, nil
+}
+
+// SREM removes members from the set by key and returns how many of them
+// were removed. An empty set is deleted.
+func (r *Storage) SREM(key string, members []any) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	st, err := r.getSet(key)
+	if err != nil || st == nil {
+		return 0, err
+	}
+	vals, err := newValues(members)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, val := range vals {
+		if _, ok := st[val]; ok {
+			delete(st, val)
+			removed++
+		}
+	}
+	if len(st) == 0 {
+		r.deleteKey(key, kindSet)
+	}
+	return removed, nil
+}
+
+func (r *Storage) SISMEMBER(key string, member any) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	st, err := r.getSet(key)
+	if err != nil {
+		return false, err
+	}
+	val, err := newValue(member)
+	if err != nil {
+		return false, err
+	}
+	_, ok := st[val]
+	return ok, nil
+}
+
+func (r *Storage) SCARD(key string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	st, err := r.getSet(key)
+	if err != nil {
+		return 0, err
+	}
+	return len(st), nil
+}
+
+// SMEMBERS returns all members of the set: integers in ascending order
+// followed by strings in lexicographical order.
+func (r *Storage) SMEMBERS(key string) ([]any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	st, err := r.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+	return st.sortedValues(), nil
+}
+
+// SPOP removes and returns up to count random members of the set.
+func (r *Storage) SPOP(key string, count int) ([]any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if count < 0 {
+		return nil, errors.New("WrongArgs")
+	}
+
+	st, err := r.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]any, 0, min(count, len(st)))
+	for _, val := range st.random(count, false) {
+		delete(st, val)
+		res = append(res, val.Val)
+	}
+	if st != nil && len(st) == 0 {
+		r.deleteKey(key, kindSet)
+	}
+	return res, nil
+}
+
+// SRANDMEMBER returns up to count distinct random members of the set.
+// If count is negative, it returns exactly -count members that may repeat,
+// at most maxRandomRepeats of them.
+func (r *Storage) SRANDMEMBER(key string, count int) ([]any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if count < -maxRandomRepeats {
+		return nil, errors.New("WrongArgs")
+	}
+
+	st, err := r.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	vals := st.random(count, count < 0)
+	res := make([]any, 0, len(vals))
+	for _, val := range vals {
+		res = append(res, val.Val)
+	}
+	return res, nil
+}
+
+func (st valueSet) random(count int, repeat bool) []value {
+	members := make([]value, 0, len(st))
+	for val := range st {
+		members = append(members, val)
+	}
+	if len(members) == 0 {
+		return members
+	}
+
+	if repeat {
+		res := make([]value, 0, -count)
+		for i := 0; i < -count; i++ {
+			res = append(res, members[rand.Intn(len(members))])
+		}
+		return res
+	}
+
+	rand.Shuffle(len(members), func(i, j int) {
+		members[i], members[j] = members[j], members[i]
+	})
+	return members[:min(count, len(members))]
+}
+
+func (r *Storage) getSets(keys []string) ([]valueSet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("WrongArgs")
+	}
+
+	res := make([]valueSet, 0, len(keys))
+	for _, key := range keys {
+		st, err := r.getSet(key)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, st)
+	}
+	return res, nil
+}
+
+func unionSets(sets []valueSet) valueSet {
+	res := make(valueSet)
+	for _, st := range sets {
+		for val := range st {
+			res[val] = struct{}{}
+		}
+	}
+	return res
+}
+
+func interSets(sets []valueSet) valueSet {
+	res := make(valueSet)
+	for val := range sets[0] {
+		inAll := true
+		for _, st := range sets[1:] {
+			if _, ok := st[val]; !ok {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			res[val] = struct{}{}
+		}
+	}
+	return res
+}
+
+func diffSets(sets []valueSet) valueSet {
+	res := make(valueSet)
+	for val := range sets[0] {
+		inOther := false
+		for _, st := range sets[1:] {
+			if _, ok := st[val]; ok {
+				inOther = true
+				break
+			}
+		}
+		if !inOther {
+			res[val] = struct{}{}
+		}
+	}
+	return res
+}
+
+func (r *Storage) setAlgebra(keys []string, op func([]valueSet) valueSet) ([]any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	sets, err := r.getSets(keys)
+	if err != nil {
+		return nil, err
+	}
+	return op(sets).sortedValues(), nil
+}
+
+// setAlgebraStore computes op over sets by keys and stores the result
+// in dst replacing its previous value. It returns the size of the result.
+func (r *Storage) setAlgebraStore(dst string, keys []string, op func([]valueSet) valueSet) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	sets, err := r.getSets(keys)
+	if err != nil {
+		return 0, err
+	}
+	res := op(sets)
+
+	if dstKind := r.getLiveStruct(dst); dstKind != kindNoStruct {
+		r.deleteKey(dst, dstKind)
+	}
+	if len(res) == 0 {
+		return 0, nil
+	}
+	r.innerSet[dst] = res
+	r.setKey(dst, kindSet)
+	r.innerExpire[dst] = 0
+	return len(res), nil
+}
+
+func (r *Storage) SUNION(keys []string) ([]any, error) {
+	return r.setAlgebra(keys, unionSets)
+}
+
+func (r *Storage) SINTER(keys []string) ([]any, error) {
+	return r.setAlgebra(keys, interSets)
+}
+
+// SDIFF returns members of the first set that are not in any of the others.
+func (r *Storage) SDIFF(keys []string) ([]any, error) {
+	return r.setAlgebra(keys, diffSets)
+}
+
+func (r *Storage) SUNIONSTORE(dst string, keys []string) (int, error) {
+	return r.setAlgebraStore(dst, keys, unionSets)
+}
+
+func (r *Storage) SINTERSTORE(dst string, keys []string) (int, error) {
+	return r.setAlgebraStore(dst, keys, interSets)
+}
+
+func (r *Storage) SDIFFSTORE(dst string, keys []string) (int, error) {
+	return r.setAlgebraStore(dst, keys, diffSets)
+}
+
+func (r *Storage) getSetState() map[string][]value {
+	res := make(map[string][]value, len(r.innerSet))
+	for key, st := range r.innerSet {
+		vals := make([]value, 0, len(st))
+		for val := range st {
+			vals = append(vals, val)
+		}
+		res[key] = vals
+	}
+	return res
+}
+
+func (r *Storage) recoverSets(state map[string][]value) {
+	for key, vals := range state {
+		if r.isExpired(key) {
+			delete(r.innerExpire, key)
+			continue
+		}
+		tempExp := r.innerExpire[key]
+		members := make([]any, 0, len(vals))
+		for _, val := range vals {
+			members = append(members, val.Val)
+		}
+		r.sadd(key, members)
+		r.innerExpire[key] = tempExp
+	}
+}
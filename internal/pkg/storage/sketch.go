@@ -0,0 +1,539 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+)
+
+const (
+	defaultTopKWidth = 8
+	defaultTopKDepth = 7
+	defaultTopKDecay = 0.9
+	// maxSketchCounters keeps counters of a sketch within 512MB.
+	maxSketchCounters = 1 << 26
+	maxTopKItems      = 1 << 16
+)
+
+type ItemCount struct {
+	Item  any `json:"item"`
+	Count int `json:"count"`
+}
+
+func checkSketchDims(width int, depth int) error {
+	if width <= 0 || depth <= 0 || width > maxSketchCounters/depth {
+		return errors.New("WrongArgs")
+	}
+	return nil
+}
+
+// saturatingAdd returns a+b for non-negative b or math.MaxInt64 if the sum
+// does not fit. Saturated counters still never underestimate.
+func saturatingAdd(a int64, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+// sketchIndex returns the column of item in the given row of a sketch
+// using double hashing.
+func sketchIndex(hash uint64, row int, width int) int {
+	h2 := mix64(hash+0x9e3779b97f4a7c15) | 1
+	return int((hash + uint64(row)*h2) % uint64(width))
+}
+
+// countMinSketch estimates item frequencies never underestimating them.
+// Every row counts items in its own columns and the estimate is the minimum
+// over rows.
+type countMinSketch struct {
+	Width    int     `json:"width"`
+	Depth    int     `json:"depth"`
+	Counters []int64 `json:"counters"`
+}
+
+func newCountMinSketch(width int, depth int) *countMinSketch {
+	return &countMinSketch{
+		Width:    width,
+		Depth:    depth,
+		Counters: make([]int64, width*depth),
+	}
+}
+
+func (cms *countMinSketch) incr(item string, incr int64) int64 {
+	hash := hashElement(item)
+	res := int64(math.MaxInt64)
+	for row := 0; row < cms.Depth; row++ {
+		i := row*cms.Width + sketchIndex(hash, row, cms.Width)
+		cms.Counters[i] = saturatingAdd(cms.Counters[i], incr)
+		res = min(res, cms.Counters[i])
+	}
+	return res
+}
+
+func (cms *countMinSketch) query(item string) int64 {
+	return cms.incr(item, 0)
+}
+
+func (r *Storage) getCMS(key string) (*countMinSketch, error) {
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindCMS && struct_kind != kindNoStruct {
+		return nil, errors.New("KeyError: this key already exists and has different type")
+	}
+	return r.innerCMS[key], nil
+}
+
+func (r *Storage) setCMS(key string, cms *countMinSketch) {
+	r.innerCMS[key] = cms
+	r.setKey(key, kindCMS)
+	r.innerExpire[key] = 0
+}
+
+// CMSINITBYDIM creates an empty count-min sketch with width counters
+// in each of depth rows.
+func (r *Storage) CMSINITBYDIM(key string, width int, depth int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := checkSketchDims(width, depth); err != nil {
+		return err
+	}
+	if r.getLiveStruct(key) != kindNoStruct {
+		return errors.New("KeyError: this key already exists")
+	}
+	r.setCMS(key, newCountMinSketch(width, depth))
+	return nil
+}
+
+// CMSINITBYPROB creates an empty count-min sketch that overestimates counts
+// by more than errorRate of the total count only with the given probability.
+func (r *Storage) CMSINITBYPROB(key string, errorRate float64, probability float64) error {
+	if !(errorRate > 0 && errorRate < 1) || !(probability > 0 && probability < 1) {
+		return errors.New("WrongArgs")
+	}
+	width := math.Ceil(math.E / errorRate)
+	if width > maxSketchCounters {
+		return errors.New("WrongArgs")
+	}
+	depth := int(math.Ceil(math.Log(1 / probability)))
+	return r.CMSINITBYDIM(key, int(width), max(depth, 1))
+}
+
+// CMSINCRBY increases counts of items and returns their new estimates.
+// Counters saturate at math.MaxInt64 instead of overflowing.
+func (r *Storage) CMSINCRBY(key string, increments []ItemCount) ([]int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(increments) == 0 {
+		return nil, errors.New("WrongArgs")
+	}
+	cms, err := r.getCMS(key)
+	if err != nil {
+		return nil, err
+	}
+	if cms == nil {
+		return nil, errors.New("KeyError")
+	}
+
+	items := make([]string, 0, len(increments))
+	for _, inc := range increments {
+		val, err := newValue(inc.Item)
+		if err != nil {
+			return nil, err
+		}
+		if inc.Count < 0 {
+			return nil, errors.New("WrongArgs")
+		}
+		items = append(items, scalarString(val))
+	}
+
+	res := make([]int, 0, len(items))
+	for i, item := range items {
+		res = append(res, int(cms.incr(item, int64(increments[i].Count))))
+	}
+	return res, nil
+}
+
+// CMSQUERY returns estimated counts of items.
+func (r *Storage) CMSQUERY(key string, items []any) ([]int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cms, err := r.getCMS(key)
+	if err != nil {
+		return nil, err
+	}
+	if cms == nil {
+		return nil, errors.New("KeyError")
+	}
+	vals, err := newValues(items)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]int, 0, len(vals))
+	for _, val := range vals {
+		res = append(res, int(cms.query(scalarString(val))))
+	}
+	return res, nil
+}
+
+// CMSMERGE stores the sum of sketches by keys multiplied by weights in dst.
+// All sketches must have the same dimensions. Nil weights mean 1 for all,
+// negative weights are not allowed. Counters saturate like in CMSINCRBY.
+func (r *Storage) CMSMERGE(dst string, keys []string, weights []int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(keys) == 0 || (weights != nil && len(weights) != len(keys)) {
+		return errors.New("WrongArgs")
+	}
+	for _, weight := range weights {
+		if weight < 0 {
+			return errors.New("WrongArgs")
+		}
+	}
+	res, err := r.getCMS(dst)
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		return errors.New("KeyError")
+	}
+
+	srcs := make([]*countMinSketch, 0, len(keys))
+	for _, key := range keys {
+		cms, err := r.getCMS(key)
+		if err != nil {
+			return err
+		}
+		if cms == nil {
+			return errors.New("KeyError")
+		}
+		if cms.Width != res.Width || cms.Depth != res.Depth {
+			return errors.New("ValueError: sketches have different dimensions")
+		}
+		srcs = append(srcs, cms)
+	}
+
+	merged := make([]int64, len(res.Counters))
+	for j, cms := range srcs {
+		weight := int64(1)
+		if weights != nil {
+			weight = int64(weights[j])
+		}
+		for i, counter := range cms.Counters {
+			if counter > 0 && weight > math.MaxInt64/counter {
+				merged[i] = math.MaxInt64
+				continue
+			}
+			merged[i] = saturatingAdd(merged[i], weight*counter)
+		}
+	}
+	res.Counters = merged
+	return nil
+}
+
+type topKBucket struct {
+	fp    uint32
+	count uint32
+}
+
+// topK tracks the k most frequent items with the HeavyKeeper algorithm.
+// Buckets count fingerprints, and a colliding item decays the count of
+// the stored one with probability decay^count, so heavy hitters survive.
+type topK struct {
+	k       int
+	width   int
+	depth   int
+	decay   float64
+	buckets []topKBucket
+	// heap is a min-heap of top items by count, and index keeps
+	// the position of every item in it.
+	heap  []ItemCount
+	index map[string]int
+}
+
+func newTopK(k int, width int, depth int, decay float64) *topK {
+	return &topK{
+		k:       k,
+		width:   width,
+		depth:   depth,
+		decay:   decay,
+		buckets: make([]topKBucket, width*depth),
+		heap:    make([]ItemCount, 0),
+		index:   make(map[string]int),
+	}
+}
+
+func (tk *topK) swap(i int, j int) {
+	tk.heap[i], tk.heap[j] = tk.heap[j], tk.heap[i]
+	tk.index[tk.heap[i].Item.(string)] = i
+	tk.index[tk.heap[j].Item.(string)] = j
+}
+
+func (tk *topK) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if tk.heap[parent].Count <= tk.heap[i].Count {
+			return
+		}
+		tk.swap(i, parent)
+		i = parent
+	}
+}
+
+func (tk *topK) down(i int) {
+	for {
+		child := 2*i + 1
+		if child >= len(tk.heap) {
+			return
+		}
+		if child+1 < len(tk.heap) && tk.heap[child+1].Count < tk.heap[child].Count {
+			child++
+		}
+		if tk.heap[i].Count <= tk.heap[child].Count {
+			return
+		}
+		tk.swap(i, child)
+		i = child
+	}
+}
+
+// push adds item to the top list if it is not full and does not have
+// the item yet.
+func (tk *topK) push(item string, count int) {
+	if _, ok := tk.index[item]; ok || len(tk.heap) >= tk.k {
+		return
+	}
+	tk.heap = append(tk.heap, ItemCount{Item: item, Count: count})
+	tk.index[item] = len(tk.heap) - 1
+	tk.up(len(tk.heap) - 1)
+}
+
+// add counts item and returns the item expelled from the top list or nil.
+func (tk *topK) add(item string) any {
+	hash := hashElement(item)
+	fp := uint32(hash >> 32)
+	var maxCount uint32
+	for row := 0; row < tk.depth; row++ {
+		b := &tk.buckets[row*tk.width+sketchIndex(hash, row, tk.width)]
+		switch {
+		case b.count == 0:
+			b.fp, b.count = fp, 1
+		case b.fp == fp:
+			b.count++
+		case rand.Float64() < math.Pow(tk.decay, float64(b.count)):
+			b.count--
+			if b.count == 0 {
+				b.fp, b.count = fp, 1
+			}
+		}
+		if b.fp == fp {
+			maxCount = max(maxCount, b.count)
+		}
+	}
+
+	count := int(maxCount)
+	if i, ok := tk.index[item]; ok {
+		if count > tk.heap[i].Count {
+			tk.heap[i].Count = count
+			tk.down(i)
+		}
+		return nil
+	}
+	if len(tk.heap) < tk.k {
+		tk.push(item, count)
+		return nil
+	}
+	if count <= tk.heap[0].Count {
+		return nil
+	}
+	expelled := tk.heap[0].Item
+	delete(tk.index, expelled.(string))
+	tk.heap[0] = ItemCount{Item: item, Count: count}
+	tk.index[item] = 0
+	tk.down(0)
+	return expelled
+}
+
+func (tk *topK) list() []ItemCount {
+	res := slices.Clone(tk.heap)
+	slices.SortStableFunc(res, func(a, b ItemCount) int {
+		return b.Count - a.Count
+	})
+	return res
+}
+
+func (r *Storage) getTopK(key string) (*topK, error) {
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindTopK && struct_kind != kindNoStruct {
+		return nil, errors.New("KeyError: this key already exists and has different type")
+	}
+	return r.innerTopK[key], nil
+}
+
+func (r *Storage) setTopK(key string, tk *topK) {
+	r.innerTopK[key] = tk
+	r.setKey(key, kindTopK)
+	r.innerExpire[key] = 0
+}
+
+// TOPKRESERVE creates an empty top list of k items. width and depth size
+// the HeavyKeeper counters and decay in (0, 1] sets how fast colliding
+// items replace each other. Zero width, depth or decay take defaults.
+func (r *Storage) TOPKRESERVE(key string, k int, width int, depth int, decay float64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if width == 0 {
+		width = defaultTopKWidth
+	}
+	if depth == 0 {
+		depth = defaultTopKDepth
+	}
+	if decay == 0 {
+		decay = defaultTopKDecay
+	}
+	if k <= 0 || k > maxTopKItems || !(decay > 0 && decay <= 1) {
+		return errors.New("WrongArgs")
+	}
+	if err := checkSketchDims(width, depth); err != nil {
+		return err
+	}
+	if r.getLiveStruct(key) != kindNoStruct {
+		return errors.New("KeyError: this key already exists")
+	}
+	r.setTopK(key, newTopK(k, width, depth, decay))
+	return nil
+}
+
+// TOPKADD counts items and returns for each of them the item it expelled
+// from the top list or nil.
+func (r *Storage) TOPKADD(key string, items []any) ([]any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(items) == 0 {
+		return nil, errors.New("WrongArgs")
+	}
+	tk, err := r.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	if tk == nil {
+		return nil, errors.New("KeyError")
+	}
+	vals, err := newValues(items)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]any, 0, len(vals))
+	for _, val := range vals {
+		res = append(res, tk.add(scalarString(val)))
+	}
+	return res, nil
+}
+
+// TOPKLIST returns the top items with their estimated counts in
+// descending order of counts.
+func (r *Storage) TOPKLIST(key string) ([]ItemCount, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	tk, err := r.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	if tk == nil {
+		return nil, errors.New("KeyError")
+	}
+	return tk.list(), nil
+}
+
+// topKState keeps buckets packed as little-endian pairs of uint32
+// fingerprint and count.
+type topKState struct {
+	K       int         `json:"k"`
+	Width   int         `json:"width"`
+	Depth   int         `json:"depth"`
+	Decay   float64     `json:"decay"`
+	Buckets []byte      `json:"buckets"`
+	Top     []ItemCount `json:"top"`
+}
+
+func (r *Storage) getSketchState() (map[string]countMinSketch, map[string]topKState) {
+	cmsState := make(map[string]countMinSketch, len(r.innerCMS))
+	for key, cms := range r.innerCMS {
+		state := *cms
+		state.Counters = slices.Clone(cms.Counters)
+		cmsState[key] = state
+	}
+
+	topKStates := make(map[string]topKState, len(r.innerTopK))
+	for key, tk := range r.innerTopK {
+		buckets := make([]byte, 0, 8*len(tk.buckets))
+		for _, b := range tk.buckets {
+			buckets = binary.LittleEndian.AppendUint32(buckets, b.fp)
+			buckets = binary.LittleEndian.AppendUint32(buckets, b.count)
+		}
+		topKStates[key] = topKState{
+			K:       tk.k,
+			Width:   tk.width,
+			Depth:   tk.depth,
+			Decay:   tk.decay,
+			Buckets: buckets,
+			Top:     slices.Clone(tk.heap),
+		}
+	}
+	return cmsState, topKStates
+}
+
+func (r *Storage) recoverSketches(cmsState map[string]countMinSketch, topKStates map[string]topKState) {
+	for key, cms := range cmsState {
+		if r.isExpired(key) {
+			delete(r.innerExpire, key)
+			continue
+		}
+		if checkSketchDims(cms.Width, cms.Depth) != nil || len(cms.Counters) != cms.Width*cms.Depth {
+			r.logger.Error("Count-min sketch " + key + " has wrong size")
+			continue
+		}
+		tempExp := r.innerExpire[key]
+		r.setCMS(key, &cms)
+		r.innerExpire[key] = tempExp
+	}
+
+	for key, inTopK := range topKStates {
+		if r.isExpired(key) {
+			delete(r.innerExpire, key)
+			continue
+		}
+		if inTopK.K <= 0 || inTopK.K > maxTopKItems || checkSketchDims(inTopK.Width, inTopK.Depth) != nil || len(inTopK.Buckets) != 8*inTopK.Width*inTopK.Depth {
+			r.logger.Error("Top-K " + key + " has wrong size")
+			continue
+		}
+		tk := newTopK(inTopK.K, inTopK.Width, inTopK.Depth, inTopK.Decay)
+		for i := range tk.buckets {
+			tk.buckets[i] = topKBucket{
+				fp:    binary.LittleEndian.Uint32(inTopK.Buckets[8*i:]),
+				count: binary.LittleEndian.Uint32(inTopK.Buckets[8*i+4:]),
+			}
+		}
+		for _, ic := range inTopK.Top {
+			val, err := newValue(ic.Item)
+			if err != nil {
+				continue
+			}
+			tk.push(scalarString(val), ic.Count)
+		}
+
+		tempExp := r.innerExpire[key]
+		r.setTopK(key, tk)
+		r.innerExpire[key] = tempExp
+	}
+}
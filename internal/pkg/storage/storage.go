@@ -1,19 +1,22 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"math"
 	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-
 type value struct {
 	Val any  `json:"value"`
 	Kin Kind `json:"type"`
@@ -21,7 +24,12 @@ type value struct {
 
 func newValue(val any) (value, error) {
 	switch k := getType(val); k {
-	case kindInt, kindString:
+	case kindInt:
+		return value{
+			Val: toInt(val),
+			Kin: k,
+		}, nil
+	case kindString:
 		return value{
 			Val: val,
 			Kin: k,
@@ -31,10 +39,21 @@ func newValue(val any) (value, error) {
 }
 
 type StorageCondition struct {
-	InnerScalar map[string]value            `json:"innerscalar"`
-	InnerArray  map[string][]value          `json:"innerarray"`
-	InnerMap    map[string]map[string]value `json:"innermap"`
-	InnerExpire map[string]int64            `json:"innerexpire"`
+	InnerScalar      map[string]value            `json:"innerscalar"`
+	InnerArray       map[string][]value          `json:"innerarray"`
+	InnerMap         map[string]map[string]value `json:"innermap"`
+	InnerExpire      map[string]int64            `json:"innerexpire"`
+	InnerFieldExpire map[string]map[string]int64 `json:"innerfieldexpire"`
+	InnerSet         map[string][]value          `json:"innerset"`
+	InnerZSet        map[string][]ZMember        `json:"innerzset"`
+	InnerStream      map[string]streamState      `json:"innerstream"`
+	InnerBitmap      map[string][]byte           `json:"innerbitmap"`
+	InnerHLL         map[string]hllState         `json:"innerhll"`
+	InnerBloom       map[string]bloomFilter      `json:"innerbloom"`
+	InnerCuckoo      map[string]cuckooState      `json:"innercuckoo"`
+	InnerCMS         map[string]countMinSketch   `json:"innercms"`
+	InnerTopK        map[string]topKState        `json:"innertopk"`
+	InnerTimeSeries  map[string]timeSeriesState  `json:"innertimeseries"`
 }
 
 type Kind string
@@ -47,19 +66,57 @@ const (
 
 type StructKind string
 
+const defaultScanCount = 10
+
 const (
-	kindScalar   StructKind = "SCALAR"
-	kindArray    StructKind = "ARRAY"
-	kindMap      StructKind = "MAP"
-	kindNoStruct StructKind = "NOSTRUCTURE"
+	kindScalar     StructKind = "SCALAR"
+	kindArray      StructKind = "ARRAY"
+	kindMap        StructKind = "MAP"
+	kindSet        StructKind = "SET"
+	kindZSet       StructKind = "ZSET"
+	kindStream     StructKind = "STREAM"
+	kindBitmap     StructKind = "BITMAP"
+	kindHLL        StructKind = "HYPERLOGLOG"
+	kindBloom      StructKind = "BLOOM"
+	kindCuckoo     StructKind = "CUCKOO"
+	kindCMS        StructKind = "CMS"
+	kindTopK       StructKind = "TOPK"
+	kindTimeSeries StructKind = "TIMESERIES"
+	kindNoStruct   StructKind = "NOSTRUCTURE"
 )
 
+// ParseStructKind returns the structure kind named by s in any letter case.
+func ParseStructKind(s string) (StructKind, bool) {
+	sKind := StructKind(strings.ToUpper(s))
+	switch sKind {
+	case kindScalar, kindArray, kindMap, kindSet, kindZSet, kindStream, kindBitmap, kindHLL,
+		kindBloom, kindCuckoo, kindCMS, kindTopK, kindTimeSeries:
+		return sKind, true
+	}
+	return "", false
+}
+
 type Storage struct {
-	innerScalar  map[string]value
-	innerArray   map[string]*Treap
-	innerMap     map[string]map[string]value
-	innerKeys    map[string]StructKind
-	innerExpire  map[string]int64
+	innerScalar     map[string]value
+	innerArray      map[string]*Treap
+	innerMap        map[string]map[string]value
+	innerSet        map[string]valueSet
+	innerZSet       map[string]*sortedSet
+	innerStream     map[string]*stream
+	innerBitmap     map[string][]byte
+	innerHLL        map[string]hyperLogLog
+	innerBloom      map[string]*bloomFilter
+	innerCuckoo     map[string]*cuckooFilter
+	innerCMS        map[string]*countMinSketch
+	innerTopK       map[string]*topK
+	innerTimeSeries map[string]*timeSeries
+	innerKeys       map[string]StructKind
+	innerIndex      *orderedTreap[string]
+	innerExpire     map[string]int64
+	// innerFieldExpire keeps expiration of single hash fields in unix milliseconds.
+	innerFieldExpire map[string]map[string]int64
+	// waiters keeps channels of blocked pops waiting for elements by key.
+	waiters      map[string][]chan struct{}
 	mutex        *sync.RWMutex
 	logger       *zap.Logger
 	dbConnection *sql.DB
@@ -92,15 +149,28 @@ func NewStorage(opts ...StorageOption) (*Storage, error) {
 	}
 
 	resStorage := &Storage{
-		innerScalar:  make(map[string]value),
-		innerArray:   make(map[string]*Treap),
-		innerKeys:    make(map[string]StructKind),
-		innerMap:     make(map[string]map[string]value),
-		innerExpire:  make(map[string]int64),
-		mutex:        new(sync.RWMutex),
-		logger:       logger,
-		dbConnection: db,
-		appCfg:       appConfig,
+		innerScalar:      make(map[string]value),
+		innerArray:       make(map[string]*Treap),
+		innerKeys:        make(map[string]StructKind),
+		innerIndex:       newOrderedTreap(lessString),
+		innerMap:         make(map[string]map[string]value),
+		innerSet:         make(map[string]valueSet),
+		innerZSet:        make(map[string]*sortedSet),
+		innerStream:      make(map[string]*stream),
+		innerBitmap:      make(map[string][]byte),
+		innerHLL:         make(map[string]hyperLogLog),
+		innerBloom:       make(map[string]*bloomFilter),
+		innerCuckoo:      make(map[string]*cuckooFilter),
+		innerCMS:         make(map[string]*countMinSketch),
+		innerTopK:        make(map[string]*topK),
+		innerTimeSeries:  make(map[string]*timeSeries),
+		innerExpire:      make(map[string]int64),
+		innerFieldExpire: make(map[string]map[string]int64),
+		waiters:          make(map[string][]chan struct{}),
+		mutex:            new(sync.RWMutex),
+		logger:           logger,
+		dbConnection:     db,
+		appCfg:           appConfig,
 	}
 
 	for _, opt := range opts {
@@ -125,34 +195,440 @@ func (r *Storage) getStruct(key string) StructKind {
 	return sKind
 }
 
-func (r *Storage) HSET(key string, field string, val any) error {
+func (r *Storage) setKey(key string, sKind StructKind) {
+	if _, ok := r.innerKeys[key]; !ok {
+		r.innerIndex.Insert(key)
+	}
+	r.innerKeys[key] = sKind
+}
+
+func (r *Storage) getLiveStruct(key string) StructKind {
+	sKind := r.getStruct(key)
+	if sKind != kindNoStruct && r.isExpired(key) {
+		r.deleteKey(key, sKind)
+		return kindNoStruct
+	}
+	return sKind
+}
+
+func (r *Storage) DEL(keys []string) int {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	struct_kind := r.getStruct(key)
-	if struct_kind == kindArray || struct_kind == kindScalar {
-		return errors.New("KeyError: this key already exists and has different type")
+	deleted := 0
+	for _, key := range keys {
+		sKind := r.getLiveStruct(key)
+		if sKind == kindNoStruct {
+			continue
+		}
+		r.deleteKey(key, sKind)
+		deleted++
 	}
+	return deleted
+}
 
-	new_val, err := newValue(val)
+func (r *Storage) EXISTS(keys []string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	found := 0
+	for _, key := range keys {
+		if r.getLiveStruct(key) != kindNoStruct {
+			found++
+		}
+	}
+	return found
+}
+
+func (r *Storage) TYPE(key string) StructKind {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.getLiveStruct(key)
+}
+
+func (r *Storage) KEYS(pattern string) []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	res := make([]string, 0)
+	for key := range r.innerKeys {
+		if r.getLiveStruct(key) == kindNoStruct {
+			continue
+		}
+		if matchPattern(pattern, key) {
+			res = append(res, key)
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+func (r *Storage) SCAN(cursor string, pattern string, count int, sKind StructKind) (string, []string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if pattern == "" {
+		pattern = "*"
+	}
+	if count <= 0 {
+		count = defaultScanCount
+	}
+
+	var after *string
+	if cursor != "" {
+		after = &cursor
+	}
+
+	res := make([]string, 0)
+	expired := make([]string, 0)
+	next := ""
+	examined := 0
+	r.innerIndex.AscendAfter(after, func(key string) bool {
+		if examined == count {
+			return false
+		}
+		examined++
+		next = key
+
+		if r.isExpired(key) {
+			expired = append(expired, key)
+			return true
+		}
+		if sKind != "" && r.innerKeys[key] != sKind {
+			return true
+		}
+		if matchPattern(pattern, key) {
+			res = append(res, key)
+		}
+		return true
+	})
+
+	for _, key := range expired {
+		r.deleteKey(key, r.innerKeys[key])
+	}
+	if examined < count {
+		next = ""
+	}
+
+	return next, res
+}
+
+// HSET sets the given fields of the hash by key and returns how many
+// of them were newly created.
+func (r *Storage) HSET(key string, fields map[string]any) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.hset(key, fields)
+}
+
+func (r *Storage) hset(key string, fields map[string]any) (int, error) {
+	if len(fields) == 0 {
+		return 0, errors.New("WrongArgs")
+	}
+
+	hash, err := r.getMap(key)
 	if err != nil {
-		r.logger.Error(err.Error())
-		return err
+		return 0, err
+	}
+
+	newVals := make(map[string]value, len(fields))
+	for field, val := range fields {
+		new_val, err := newValue(val)
+		if err != nil {
+			r.logger.Error(err.Error())
+			return 0, err
+		}
+		newVals[field] = new_val
+	}
+
+	if hash == nil {
+		hash = make(map[string]value)
+		r.innerMap[key] = hash
+		r.setKey(key, kindMap)
+		r.innerExpire[key] = 0
+	}
+
+	created := 0
+	for field, new_val := range newVals {
+		if _, ok := hash[field]; !ok {
+			created++
+		}
+		hash[field] = new_val
+		delete(r.innerFieldExpire[key], field)
+	}
+	return created, nil
+}
+
+func (r *Storage) getMap(key string) (map[string]value, error) {
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindMap && struct_kind != kindNoStruct {
+		return nil, errors.New("KeyError: this key already exists and has different type")
+	}
+	if struct_kind == kindMap {
+		r.expireFields(key)
 	}
+	return r.innerMap[key], nil
+}
 
-	_, ok := r.innerMap[key]
+// expireFields removes expired fields of the hash by key and the hash
+// itself if no fields are left.
+func (r *Storage) expireFields(key string) {
+	fieldExpire, ok := r.innerFieldExpire[key]
 	if !ok {
-		r.innerMap[key] = make(map[string]value)
+		return
 	}
-	r.innerMap[key][field] = new_val
-	r.innerExpire[key] = 0
-	return nil
+
+	now := time.Now().UnixMilli()
+	for field, expireAt := range fieldExpire {
+		if expireAt < now {
+			delete(r.innerMap[key], field)
+			delete(fieldExpire, field)
+		}
+	}
+	if len(fieldExpire) == 0 {
+		delete(r.innerFieldExpire, key)
+	}
+	if len(r.innerMap[key]) == 0 {
+		r.deleteKey(key, kindMap)
+	}
+}
+
+// HEXPIRE sets time to live of the hash fields. For every field it returns
+// -2 if there is no such field, 2 if the field was deleted because secs is 0,
+// and 1 if the expiration was set.
+func (r *Storage) HEXPIRE(key string, fields []string, secs int64) ([]int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if secs < 0 || len(fields) == 0 {
+		return nil, errors.New("WrongArgs")
+	}
+
+	hash, err := r.getMap(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]int, len(fields))
+	expireAt := time.Now().Add(time.Duration(secs * int64(time.Second))).UnixMilli()
+	for i, field := range fields {
+		if _, ok := hash[field]; !ok {
+			res[i] = -2
+			continue
+		}
+		if secs == 0 {
+			delete(hash, field)
+			delete(r.innerFieldExpire[key], field)
+			res[i] = 2
+			continue
+		}
+		if _, ok := r.innerFieldExpire[key]; !ok {
+			r.innerFieldExpire[key] = make(map[string]int64)
+		}
+		r.innerFieldExpire[key][field] = expireAt
+		res[i] = 1
+	}
+	if hash != nil && len(hash) == 0 {
+		r.deleteKey(key, kindMap)
+	}
+	return res, nil
+}
+
+// HTTL returns remaining time to live of the hash fields in seconds,
+// -1 for fields without expiration and -2 for missing fields.
+func (r *Storage) HTTL(key string, fields []string) ([]int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	hash, err := r.getMap(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]int64, len(fields))
+	now := time.Now().UnixMilli()
+	for i, field := range fields {
+		if _, ok := hash[field]; !ok {
+			res[i] = -2
+			continue
+		}
+		expireAt, ok := r.innerFieldExpire[key][field]
+		if !ok {
+			res[i] = -1
+			continue
+		}
+		res[i] = (expireAt - now + 999) / 1000
+	}
+	return res, nil
+}
+
+// HPERSIST removes expiration of the hash fields. For every field it returns
+// 1 if the expiration was removed, -1 if the field had none and -2 if
+// there is no such field.
+func (r *Storage) HPERSIST(key string, fields []string) ([]int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	hash, err := r.getMap(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]int, len(fields))
+	for i, field := range fields {
+		if _, ok := hash[field]; !ok {
+			res[i] = -2
+			continue
+		}
+		if _, ok := r.innerFieldExpire[key][field]; !ok {
+			res[i] = -1
+			continue
+		}
+		delete(r.innerFieldExpire[key], field)
+		res[i] = 1
+	}
+	if len(r.innerFieldExpire[key]) == 0 {
+		delete(r.innerFieldExpire, key)
+	}
+	return res, nil
+}
+
+func (r *Storage) HSETNX(key string, field string, val any) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	hash, err := r.getMap(key)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := hash[field]; ok {
+		return false, nil
+	}
+
+	_, err = r.hset(key, map[string]any{field: val})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *Storage) HDEL(key string, fields []string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	hash, err := r.getMap(key)
+	if err != nil || hash == nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, field := range fields {
+		if _, ok := hash[field]; ok {
+			delete(hash, field)
+			delete(r.innerFieldExpire[key], field)
+			deleted++
+		}
+	}
+	if len(hash) == 0 {
+		r.deleteKey(key, kindMap)
+	}
+	return deleted, nil
+}
+
+func (r *Storage) HGETALL(key string) (map[string]any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	hash, err := r.getMap(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]any, len(hash))
+	for field, val := range hash {
+		res[field] = val.Val
+	}
+	return res, nil
+}
+
+func (r *Storage) HKEYS(key string) ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	hash, err := r.getMap(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(hash))
+	for field := range hash {
+		res = append(res, field)
+	}
+	slices.Sort(res)
+	return res, nil
+}
+
+// HVALS returns values of the hash ordered by their fields, as in HKEYS.
+func (r *Storage) HVALS(key string) ([]any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	hash, err := r.getMap(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(hash))
+	for field := range hash {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	res := make([]any, 0, len(hash))
+	for _, field := range fields {
+		res = append(res, hash[field].Val)
+	}
+	return res, nil
+}
+
+func (r *Storage) HLEN(key string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	hash, err := r.getMap(key)
+	if err != nil {
+		return 0, err
+	}
+	return len(hash), nil
+}
+
+func (r *Storage) HEXISTS(key string, field string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	hash, err := r.getMap(key)
+	if err != nil {
+		return false, err
+	}
+	_, ok := hash[field]
+	return ok, nil
 }
 
 func (r *Storage) HGET(key string, field string) *any {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
+	if _, err := r.getMap(key); err != nil {
+		r.logger.Error(err.Error())
+		return nil
+	}
+
 	res, ok := r.hget(key, field)
 
 	if !ok {
@@ -162,307 +638,1382 @@ func (r *Storage) HGET(key string, field string) *any {
 		return nil
 	}
 
-	if r.isExpired(key) {
-		r.deleteKey(key, kindMap)
-		return nil
+	return &res.Val
+}
+
+func (r *Storage) HSCAN(key string, cursor string, pattern string, count int) (string, map[string]any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	hash, err := r.getMap(key)
+	if err != nil {
+		return "", nil, err
+	}
+	if hash == nil {
+		return "", nil, errors.New("KeyError")
+	}
+
+	if pattern == "" {
+		pattern = "*"
+	}
+	if count <= 0 {
+		count = defaultScanCount
+	}
+
+	// Hashes have no ordered index, so the next page is the count smallest
+	// fields after the cursor. This keeps pages stable between calls.
+	page := make([]string, 0, min(count, len(hash))+1)
+	for field := range hash {
+		if cursor != "" && field <= cursor {
+			continue
+		}
+		idx, _ := slices.BinarySearch(page, field)
+		if idx == count {
+			continue
+		}
+		page = slices.Insert(page, idx, field)
+		if len(page) > count {
+			page = page[:count]
+		}
+	}
+
+	res := make(map[string]any)
+	for _, field := range page {
+		if matchPattern(pattern, field) {
+			res[field] = hash[field].Val
+		}
+	}
+
+	next := ""
+	if len(page) == count {
+		next = page[len(page)-1]
+	}
+
+	return next, res, nil
+}
+
+func (r *Storage) HINCRBY(key string, field string, delta int) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, err := r.getMap(key); err != nil {
+		return 0, err
+	}
+
+	cur, ok := r.hget(key, field)
+	res, err := incrValue(cur, ok, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, ok := r.innerMap[key]; !ok {
+		r.innerMap[key] = make(map[string]value)
+		r.setKey(key, kindMap)
+		r.innerExpire[key] = 0
+	}
+	r.innerMap[key][field] = res
+	return res.Val.(int), nil
+}
+
+func (r *Storage) hget(key string, field string) (value, bool) {
+	res, ok := r.innerMap[key][field]
+	if !ok {
+		return value{}, false
+	}
+	return res, true
+}
+
+type SetOption func(*setOptions)
+
+type setOptions struct {
+	nx      bool
+	xx      bool
+	get     bool
+	keepTTL bool
+}
+
+// SetNX makes SET write the value only if the key does not exist.
+func SetNX() SetOption {
+	return func(opts *setOptions) {
+		opts.nx = true
+	}
+}
+
+// SetXX makes SET write the value only if the key already exists.
+func SetXX() SetOption {
+	return func(opts *setOptions) {
+		opts.xx = true
+	}
+}
+
+// SetGet makes SET return the value stored before the call.
+func SetGet() SetOption {
+	return func(opts *setOptions) {
+		opts.get = true
+	}
+}
+
+// SetKeepTTL makes SET keep the current expiration of the key.
+func SetKeepTTL() SetOption {
+	return func(opts *setOptions) {
+		opts.keepTTL = true
+	}
+}
+
+// SET writes val by key. It reports whether the value was written and,
+// with SetGet, returns the previous value.
+func (r *Storage) SET(key string, val any, expireAt int64, opts ...SetOption) (bool, *any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	setOpts := setOptions{}
+	for _, opt := range opts {
+		opt(&setOpts)
+	}
+	if (setOpts.nx && setOpts.xx) || (setOpts.keepTTL && expireAt != 0) {
+		return false, nil, errors.New("WrongArgs")
+	}
+
+	cur, exists, err := r.getScalar(key)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var old *any
+	if setOpts.get && exists {
+		old = &cur.Val
+	}
+
+	if (setOpts.nx && exists) || (setOpts.xx && !exists) {
+		return false, old, nil
+	}
+
+	if setOpts.keepTTL {
+		tempExp := r.innerExpire[key]
+		err = r.set(key, val, 0)
+		r.innerExpire[key] = tempExp
+	} else {
+		err = r.set(key, val, expireAt)
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	return true, old, nil
+}
+
+func (r *Storage) set(key string, val any, expireAt int64) error {
+	struct_kind := r.getStruct(key)
+	if struct_kind != kindScalar && struct_kind != kindNoStruct {
+		return errors.New("KeyError: this key already exists and has different type")
+	}
+	new_val, err := newValue(val)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return err
+	}
+	r.innerScalar[key] = new_val
+	r.setKey(key, kindScalar)
+	r.expire(key, expireAt)
+
+	return nil
+}
+
+func (r *Storage) GET(key string) *any {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	res, ok := r.get(key)
+
+	if !ok {
+		r.logger.Error("KeyError",
+			zap.String("Wrong key", key),
+		)
+		return nil
+	}
+	return &res.Val
+}
+
+func (r *Storage) MGET(keys []string) []*any {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	res := make([]*any, len(keys))
+	for i, key := range keys {
+		if val, ok := r.get(key); ok {
+			res[i] = &val.Val
+		}
+	}
+	return res
+}
+
+func (r *Storage) MSET(pairs map[string]any) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.validatePairs(pairs); err != nil {
+		return err
+	}
+	for key := range pairs {
+		if _, _, err := r.getScalar(key); err != nil {
+			return err
+		}
+	}
+	for key, val := range pairs {
+		r.set(key, val, 0)
+	}
+	return nil
+}
+
+// MSETNX writes all pairs only if none of the keys exist, whatever
+// their type is.
+func (r *Storage) MSETNX(pairs map[string]any) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.validatePairs(pairs); err != nil {
+		return false, err
+	}
+	for key := range pairs {
+		if r.getLiveStruct(key) != kindNoStruct {
+			return false, nil
+		}
+	}
+	for key, val := range pairs {
+		r.set(key, val, 0)
+	}
+	return true, nil
+}
+
+func (r *Storage) validatePairs(pairs map[string]any) error {
+	if len(pairs) == 0 {
+		return errors.New("WrongArgs")
+	}
+	for _, val := range pairs {
+		if _, err := newValue(val); err != nil {
+			r.logger.Error(err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Storage) GetKind(key string) (Kind, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	res, ok := r.get(key)
+	if !ok {
+		return kindUndefind, false
+	}
+	return res.Kin, true
+}
+
+func (r *Storage) get(key string) (value, bool) {
+	res, ok := r.innerScalar[key]
+	if !ok {
+		return value{}, false
+	}
+	if r.isExpired(key) {
+		r.deleteKey(key, kindScalar)
+		return value{}, false
+	}
+	return res, true
+}
+
+func (r *Storage) getScalar(key string) (value, bool, error) {
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindScalar && struct_kind != kindNoStruct {
+		return value{}, false, errors.New("KeyError: this key already exists and has different type")
+	}
+	res, ok := r.innerScalar[key]
+	return res, ok, nil
+}
+
+func scalarString(val value) string {
+	if val.Kin == kindInt {
+		return strconv.Itoa(toInt(val.Val))
+	}
+	return val.Val.(string)
+}
+
+// APPEND appends str to the value by key. The result is limited to 512MB
+// like in SETRANGE.
+func (r *Storage) APPEND(key string, str string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cur, ok, err := r.getScalar(key)
+	if err != nil {
+		return 0, err
+	}
+	prefix := ""
+	if ok {
+		prefix = scalarString(cur)
+	}
+	if len(str) > maxBitOffset/8+1-len(prefix) {
+		return 0, errors.New("ValueError: string exceeds maximum allowed size")
+	}
+	if !ok {
+		r.set(key, str, 0)
+		return len(str), nil
+	}
+
+	res := prefix + str
+	r.innerScalar[key] = value{
+		Val: res,
+		Kin: kindString,
+	}
+	return len(res), nil
+}
+
+func (r *Storage) STRLEN(key string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cur, ok, err := r.getScalar(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return len(scalarString(cur)), nil
+}
+
+func (r *Storage) GETRANGE(key string, start int, end int) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cur, ok, err := r.getScalar(key)
+	if err != nil || !ok {
+		return "", err
+	}
+
+	str := scalarString(cur)
+	strLen := len(str)
+	if start < 0 {
+		start = max(strLen+start, 0)
+	}
+	if end < 0 {
+		end = strLen + end
+	}
+	end = min(end, strLen-1)
+	if start > end {
+		return "", nil
+	}
+	return str[start : end+1], nil
+}
+
+// SETRANGE overwrites the string from offset, padding it with zero bytes
+// if needed. The result is limited to 512MB like bitmaps.
+func (r *Storage) SETRANGE(key string, offset int, str string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if offset < 0 {
+		return 0, errors.New("IndexOutOfRange")
+	}
+	if offset > maxBitOffset/8+1-len(str) {
+		return 0, errors.New("ValueError: string exceeds maximum allowed size")
+	}
+
+	cur, ok, err := r.getScalar(key)
+	if err != nil {
+		return 0, err
+	}
+
+	buf := []byte{}
+	if ok {
+		buf = []byte(scalarString(cur))
+	}
+	if len(str) == 0 {
+		return len(buf), nil
+	}
+	if need := offset + len(str); need > len(buf) {
+		buf = append(buf, make([]byte, need-len(buf))...)
+	}
+	copy(buf[offset:], str)
+
+	if !ok {
+		r.set(key, string(buf), 0)
+		return len(buf), nil
+	}
+	r.innerScalar[key] = value{
+		Val: string(buf),
+		Kin: kindString,
+	}
+	return len(buf), nil
+}
+
+func (r *Storage) GETSET(key string, val any) (*any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cur, ok, err := r.getScalar(key)
+	if err != nil {
+		return nil, err
+	}
+
+	new_val, err := newValue(val)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, err
+	}
+
+	if !ok {
+		r.set(key, val, 0)
+		return nil, nil
+	}
+	r.innerScalar[key] = new_val
+	return &cur.Val, nil
+}
+
+func (r *Storage) GETDEL(key string) (*any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cur, ok, err := r.getScalar(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	r.deleteKey(key, kindScalar)
+	return &cur.Val, nil
+}
+
+// GETEX returns the value by key and changes its expiration: secs > 0 sets
+// a new time to live, persist removes it, otherwise expiration is untouched.
+func (r *Storage) GETEX(key string, secs int64, persist bool) (*any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if secs < 0 {
+		return nil, errors.New("WrongArgs")
+	}
+
+	cur, ok, err := r.getScalar(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if persist {
+		r.expire(key, 0)
+	} else if secs > 0 {
+		r.expire(key, secs)
+	}
+	return &cur.Val, nil
+}
+
+func (r *Storage) INCR(key string) (int, error) {
+	return r.INCRBY(key, 1)
+}
+
+func (r *Storage) DECR(key string) (int, error) {
+	return r.INCRBY(key, -1)
+}
+
+func (r *Storage) DECRBY(key string, delta int) (int, error) {
+	if delta == math.MinInt {
+		return 0, errors.New("ValueError: increment would overflow")
+	}
+	return r.INCRBY(key, -delta)
+}
+
+func (r *Storage) INCRBY(key string, delta int) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindScalar && struct_kind != kindNoStruct {
+		return 0, errors.New("KeyError: this key already exists and has different type")
+	}
+
+	cur, ok := r.innerScalar[key]
+	res, err := incrValue(cur, ok, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	r.innerScalar[key] = res
+	if !ok {
+		r.setKey(key, kindScalar)
+		r.innerExpire[key] = 0
+	}
+	return res.Val.(int), nil
+}
+
+func incrValue(cur value, ok bool, delta int) (value, error) {
+	num := 0
+	if ok {
+		if cur.Kin != kindInt {
+			return value{}, errors.New("ValueError: value is not an integer")
+		}
+		num = toInt(cur.Val)
+	}
+	if (delta > 0 && num > math.MaxInt-delta) || (delta < 0 && num < math.MinInt-delta) {
+		return value{}, errors.New("ValueError: increment would overflow")
+	}
+	return value{
+		Val: num + delta,
+		Kin: kindInt,
+	}, nil
+}
+
+func toInt(val any) int {
+	switch v := val.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+func getType(val any) Kind {
+	switch val.(type) {
+	case int:
+		return kindInt
+	case float64:
+		if isFloatInt(val) {
+			return kindInt
+		}
+		return kindUndefind
+	case string:
+		return kindString
+	default:
+		return kindUndefind
+	}
+}
+
+func (r *Storage) LPUSH(key string, args []any) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(args) == 0 {
+		return errors.New("WrongArgs")
+	}
+
+	struct_kind := r.getStruct(key)
+	if struct_kind != kindArray && struct_kind != kindNoStruct {
+		return errors.New("KeyError: this key already exists and has different type")
+	}
+
+	if _, ok := r.innerArray[key]; !ok {
+		r.innerArray[key] = NewTreap()
+		r.innerExpire[key] = 0
+	}
+
+	if r.isExpired(key) {
+		r.deleteKey(key, kindArray)
+		return errors.New("KeyExpired")
+	}
+
+	for _, arg := range args {
+		err := r.innerArray[key].PushFront(arg)
+		if err != nil {
+			return err
+		}
+	}
+	r.setKey(key, kindArray)
+	r.notifyKey(key)
+
+	return nil
+}
+
+func (r *Storage) RPUSH(key string, args []any) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.rpush(key, args)
+}
+
+func (r *Storage) rpush(key string, args []any) error {
+	if len(args) == 0 {
+		return errors.New("WrongArgs")
+	}
+
+	struct_kind := r.getStruct(key)
+	if struct_kind != kindArray && struct_kind != kindNoStruct {
+		return errors.New("KeyError: this key already exists and has different type")
+	}
+
+	if _, ok := r.innerArray[key]; !ok {
+		r.innerArray[key] = NewTreap()
+		r.innerExpire[key] = 0
+	}
+
+	if r.isExpired(key) {
+		r.deleteKey(key, kindArray)
+		return errors.New("KeyExpired")
+	}
+
+	for _, arg := range args {
+		err := r.innerArray[key].PushBack(arg)
+		if err != nil {
+			return err
+		}
+	}
+	r.setKey(key, kindArray)
+	r.notifyKey(key)
+
+	return nil
+}
+
+func (r *Storage) RADDTOSET(key string, args []any) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(args) == 0 {
+		return errors.New("WrongArgs")
+	}
+
+	struct_kind := r.getStruct(key)
+	if struct_kind != kindArray && struct_kind != kindNoStruct {
+		return errors.New("KeyError: this key already exists and has different type")
+	}
+
+	if _, ok := r.innerArray[key]; !ok {
+		r.innerArray[key] = NewTreap()
+		r.innerExpire[key] = 0
+	}
+
+	if r.isExpired(key) {
+		r.deleteKey(key, kindArray)
+		return errors.New("KeyExpired")
+	}
+
+	for _, arg := range args {
+		err := r.innerArray[key].PushBackToSet(arg)
+		if err != nil {
+			return err
+		}
+	}
+	r.setKey(key, kindArray)
+	r.notifyKey(key)
+
+	return nil
+}
+
+func (r *Storage) LPOP(key string, args []int) ([]any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(args) > 2 {
+		return nil, errors.New("WrongArgs")
+	}
+
+	if r.isExpired(key) {
+		r.deleteKey(key, kindArray)
+	}
+
+	trp, ok := r.innerArray[key]
+	if !ok {
+		r.logger.Error("KeyError", zap.String("Key doesn't exist", key))
+		return nil, errors.New("KeyError")
+	}
+
+	rt, lf, err := trp.ValidateEraseSlice(args, true)
+	if err != nil {
+		return nil, err
+	}
+	nodes := trp.EraseSection(rt, lf)
+
+	return nodes, nil
+}
+
+func (r *Storage) RPOP(key string, args []int) ([]any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(args) > 2 {
+		return nil, errors.New("WrongArgs")
+	}
+
+	if r.isExpired(key) {
+		r.deleteKey(key, kindArray)
+	}
+
+	trp, ok := r.innerArray[key]
+	if !ok {
+		r.logger.Error("KeyError", zap.String("Key doesn't exist", key))
+		return nil, errors.New("KeyError")
+	}
+
+	rt, lf, err := trp.ValidateEraseSlice(args, false)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := trp.EraseSection(rt, lf)
+	slices.Reverse(nodes)
+	return nodes, nil
+}
+
+func (r *Storage) LSET(key string, index int, val any) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.isExpired(key) {
+		r.deleteKey(key, kindArray)
+		return errors.New("KeyExpired")
+	}
+
+	trp, ok := r.innerArray[key]
+	if !ok {
+		r.logger.Error("KeyError", zap.String("Key doesn't exist", key))
+		return errors.New("KeyError")
+	}
+	if trp.Set(index, val) {
+		return nil
+	}
+
+	return errors.New("IndexOutOfRange")
+}
+
+func (r *Storage) LGET(key string, index int) (any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.isExpired(key) {
+		r.deleteKey(key, kindArray)
+		return nil, errors.New("KeyExpired")
+	}
+
+	trp, ok := r.innerArray[key]
+	if !ok {
+		r.logger.Error("KeyError", zap.String("Key doesn't exist", key))
+		return nil, errors.New("KeyError")
+	}
+
+	ans, ok := trp.Get(index)
+	if !ok {
+		return nil, errors.New("IndexOutOfRange")
+	}
+
+	return ans, nil
+}
+
+func (r *Storage) getArray(key string) (*Treap, error) {
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindArray && struct_kind != kindNoStruct {
+		return nil, errors.New("KeyError: this key already exists and has different type")
+	}
+	return r.innerArray[key], nil
+}
+
+// normalizeRange converts inclusive start and stop indexes, which may be
+// negative to count from the end, into a valid range of an array of size.
+// It reports false if the range is empty.
+func normalizeRange(start, stop, size int) (int, int, bool) {
+	if start < 0 {
+		start = max(size+start, 0)
+	}
+	if stop < 0 {
+		stop = size + stop
+	}
+	stop = min(stop, size-1)
+	if start > stop {
+		return 0, 0, false
+	}
+	return start, stop, true
+}
+
+func (r *Storage) LRANGE(key string, start int, stop int) ([]any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	trp, err := r.getArray(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]any, 0)
+	if trp == nil {
+		return res, nil
+	}
+	start, stop, ok := normalizeRange(start, stop, trp.GetSize())
+	if !ok {
+		return res, nil
+	}
+
+	for _, val := range trp.Range(start, stop) {
+		res = append(res, val.Val)
+	}
+	return res, nil
+}
+
+func (r *Storage) LLEN(key string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	trp, err := r.getArray(key)
+	if err != nil || trp == nil {
+		return 0, err
+	}
+	return trp.GetSize(), nil
+}
+
+// LINSERT inserts val before or after the first element equal to pivot.
+// It returns the new length of the array, -1 if pivot was not found
+// and 0 if there is no array by key.
+func (r *Storage) LINSERT(key string, before bool, pivot any, val any) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	pivotVal, err := newValue(pivot)
+	if err != nil {
+		return 0, err
+	}
+
+	trp, err := r.getArray(key)
+	if err != nil || trp == nil {
+		return 0, err
+	}
+
+	pos := trp.Positions(pivotVal)
+	if len(pos) == 0 {
+		return -1, nil
+	}
+
+	index := pos[0]
+	if !before {
+		index++
+	}
+	if err := trp.InsertAt(index, val); err != nil {
+		return 0, err
+	}
+	return trp.GetSize(), nil
+}
+
+// LREM removes up to count elements equal to val: from the head if count
+// is positive, from the tail if it is negative and all of them if it is 0.
+// It returns the number of removed elements.
+func (r *Storage) LREM(key string, count int, val any) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	remVal, err := newValue(val)
+	if err != nil {
+		return 0, err
+	}
+
+	trp, err := r.getArray(key)
+	if err != nil || trp == nil {
+		return 0, err
+	}
+
+	pos := trp.Positions(remVal)
+	if count > 0 && count < len(pos) {
+		pos = pos[:count]
+	} else if count < 0 && -count < len(pos) {
+		pos = pos[len(pos)+count:]
+	}
+
+	for i := len(pos) - 1; i >= 0; i-- {
+		trp.EraseSection(pos[i], pos[i])
+	}
+	if trp.GetSize() == 0 {
+		r.deleteKey(key, kindArray)
+	}
+	return len(pos), nil
+}
+
+// LTRIM keeps only elements from start to stop inclusive. Indexes may be
+// negative to count from the end. An empty range deletes the array.
+func (r *Storage) LTRIM(key string, start int, stop int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	trp, err := r.getArray(key)
+	if err != nil || trp == nil {
+		return err
+	}
+
+	size := trp.GetSize()
+	start, stop, ok := normalizeRange(start, stop, size)
+	if !ok {
+		r.deleteKey(key, kindArray)
+		return nil
+	}
+
+	if stop < size-1 {
+		trp.EraseSection(stop+1, size-1)
+	}
+	if start > 0 {
+		trp.EraseSection(0, start-1)
+	}
+	return nil
+}
+
+// LPOS returns indexes of elements equal to val. rank selects the match
+// to start from: 1 is the first match from the head, -1 the first from
+// the tail. count limits the number of returned indexes, 0 means all.
+func (r *Storage) LPOS(key string, val any, rank int, count int) ([]int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if rank == 0 || count < 0 {
+		return nil, errors.New("WrongArgs")
+	}
+
+	posVal, err := newValue(val)
+	if err != nil {
+		return nil, err
+	}
+
+	trp, err := r.getArray(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]int, 0)
+	if trp == nil {
+		return res, nil
 	}
 
-	return &res.Val
+	pos := trp.Positions(posVal)
+	if rank < 0 {
+		slices.Reverse(pos)
+		rank = -rank
+	}
+	if rank > len(pos) {
+		return res, nil
+	}
+	pos = pos[rank-1:]
+	if count > 0 && count < len(pos) {
+		pos = pos[:count]
+	}
+	return append(res, pos...), nil
 }
 
-func (r *Storage) hget(key string, field string) (value, bool) {
+// LREVERSE reverses elements of the array from start to stop inclusive
+// in O(log n). Indexes may be negative to count from the end.
+func (r *Storage) LREVERSE(key string, start int, stop int) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	res, ok := r.innerMap[key][field]
-	if !ok {
-		return value{}, false
+	trp, err := r.getArray(key)
+	if err != nil || trp == nil {
+		return err
 	}
-	return res, true
+
+	start, stop, ok := normalizeRange(start, stop, trp.GetSize())
+	if ok {
+		trp.Reverse(start, stop)
+	}
+	return nil
 }
 
-func (r *Storage) SET(key string, val any, expireAt int64) error {
+// LROTATE rotates the array by k positions: the last k elements move
+// to the front, or the first -k elements move to the back if k is negative.
+func (r *Storage) LROTATE(key string, k int) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	struct_kind := r.getStruct(key)
-	if struct_kind == kindArray || struct_kind == kindMap {
-		return errors.New("KeyError: this key already exists and has different type")
-	}
-	new_val, err := newValue(val)
-	if err != nil {
-		r.logger.Error(err.Error())
+	trp, err := r.getArray(key)
+	if err != nil || trp == nil {
 		return err
 	}
-	r.innerScalar[key] = new_val
-	r.innerKeys[key] = kindScalar
-	r.Expire(key, expireAt)
 
+	trp.Rotate(k)
 	return nil
 }
 
-func (r *Storage) GET(key string) *any {
+func (r *Storage) aggregateRange(key string, start int, stop int) (aggregate, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	res, ok := r.get(key)
+	trp, err := r.getArray(key)
+	if err != nil || trp == nil {
+		return aggregate{}, err
+	}
 
+	start, stop, ok := normalizeRange(start, stop, trp.GetSize())
 	if !ok {
-		r.logger.Error("KeyError",
-			zap.String("Wrong key", key),
-		)
-		return nil
+		return aggregate{}, nil
 	}
-	return &res.Val
+	return trp.Aggregate(start, stop), nil
 }
 
-func (r *Storage) GetKind(key string) (Kind, bool) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
-	res, ok := r.get(key)
-	if !ok {
-		return kindUndefind, false
+// LSUM returns the sum of integer elements of the array from start
+// to stop inclusive. String elements are skipped.
+func (r *Storage) LSUM(key string, start int, stop int) (int, error) {
+	agg, err := r.aggregateRange(key, start, stop)
+	if err != nil {
+		return 0, err
 	}
-	return res.Kin, true
+	if agg.overflow {
+		return 0, errors.New("ValueError: sum would overflow")
+	}
+	return agg.Sum, nil
 }
 
-func (r *Storage) get(key string) (value, bool) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+// LCOUNT returns the number of integer elements of the array from start
+// to stop inclusive.
+func (r *Storage) LCOUNT(key string, start int, stop int) (int, error) {
+	agg, err := r.aggregateRange(key, start, stop)
+	return agg.Count, err
+}
 
-	res, ok := r.innerScalar[key]
-	if !ok {
-		return value{}, false
+// LMIN returns the minimum of integer elements of the array from start
+// to stop inclusive.
+func (r *Storage) LMIN(key string, start int, stop int) (int, error) {
+	agg, err := r.aggregateRange(key, start, stop)
+	if err != nil {
+		return 0, err
 	}
-	if r.isExpired(key) {
-		r.deleteKey(key, kindScalar)
-		return value{}, false
+	if agg.Count == 0 {
+		return 0, errors.New("ValueError: no integer values in range")
 	}
-	return res, true
+	return agg.Min, nil
 }
 
-func getType(val any) Kind {
-	switch val.(type) {
-	case int:
-		return kindInt
-	case float64:
-		if isFloatInt(val) {
-			return kindInt
-		}
-		return kindUndefind
-	case string:
-		return kindString
-	default:
-		return kindUndefind
+// LMAX returns the maximum of integer elements of the array from start
+// to stop inclusive.
+func (r *Storage) LMAX(key string, start int, stop int) (int, error) {
+	agg, err := r.aggregateRange(key, start, stop)
+	if err != nil {
+		return 0, err
 	}
+	if agg.Count == 0 {
+		return 0, errors.New("ValueError: no integer values in range")
+	}
+	return agg.Max, nil
 }
 
-func (r *Storage) LPUSH(key string, args []any) error {
+func (r *Storage) applyRange(key string, start int, stop int, mul int, add int) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if len(args) == 0 {
-		return errors.New("WrongArgs")
-	}
-
-	struct_kind := r.getStruct(key)
-	if struct_kind == kindScalar || struct_kind == kindMap {
-		return errors.New("KeyError: this key already exists and has different type")
+	trp, err := r.getArray(key)
+	if err != nil {
+		return err
 	}
-
-	if _, ok := r.innerArray[key]; !ok {
-		r.innerArray[key] = NewTreap()
-		r.innerExpire[key] = 0
+	if trp == nil {
+		r.logger.Error("KeyError", zap.String("Key doesn't exist", key))
+		return errors.New("KeyError")
 	}
 
-	if r.isExpired(key) {
-		r.deleteKey(key, kindArray)
-		return errors.New("KeyExpired")
+	start, stop, ok := normalizeRange(start, stop, trp.GetSize())
+	if !ok {
+		return nil
 	}
+	return trp.ApplyRange(start, stop, mul, add)
+}
 
-	for _, arg := range args {
-		err := r.innerArray[key].PushFront(arg)
-		if err != nil {
-			return err
-		}
-	}
-	r.innerKeys[key] = kindArray
+// LINCRRANGE adds delta to every element of the array from start to stop
+// inclusive in O(log n). All elements of the range must be integers.
+func (r *Storage) LINCRRANGE(key string, start int, stop int, delta int) error {
+	return r.applyRange(key, start, stop, 1, delta)
+}
 
-	return nil
+// LMULRANGE multiplies every element of the array from start to stop
+// inclusive by factor in O(log n). All elements of the range must be integers.
+func (r *Storage) LMULRANGE(key string, start int, stop int, factor int) error {
+	return r.applyRange(key, start, stop, factor, 0)
 }
 
-func (r *Storage) RPUSH(key string, args []any) error {
+// LCONCAT moves all elements of the array src to the end of the array dst
+// in O(log n) and deletes src. It returns the new length of dst.
+func (r *Storage) LCONCAT(dst string, src string) (int, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if len(args) == 0 {
-		return errors.New("WrongArgs")
+	if dst == src {
+		return 0, errors.New("WrongArgs")
 	}
 
-	struct_kind := r.getStruct(key)
-	if struct_kind == kindScalar || struct_kind == kindMap {
-		return errors.New("KeyError: this key already exists and has different type")
+	srcTrp, err := r.getArray(src)
+	if err != nil {
+		return 0, err
 	}
-
-	if _, ok := r.innerArray[key]; !ok {
-		r.innerArray[key] = NewTreap()
-		r.innerExpire[key] = 0
+	if srcTrp == nil {
+		r.logger.Error("KeyError", zap.String("Key doesn't exist", src))
+		return 0, errors.New("KeyError")
 	}
-
-	if r.isExpired(key) {
-		r.deleteKey(key, kindArray)
-		return errors.New("KeyExpired")
+	dstTrp, err := r.getArray(dst)
+	if err != nil {
+		return 0, err
 	}
 
-	for _, arg := range args {
-		err := r.innerArray[key].PushBack(arg)
-		if err != nil {
-			return err
-		}
+	if dstTrp == nil {
+		dstTrp = NewTreap()
+		r.innerArray[dst] = dstTrp
+		r.setKey(dst, kindArray)
+		r.innerExpire[dst] = r.innerExpire[src]
 	}
-	r.innerKeys[key] = kindArray
+	dstTrp.Concat(srcTrp)
+	r.deleteKey(src, kindArray)
+	r.notifyKey(dst)
 
-	return nil
+	return dstTrp.GetSize(), nil
 }
 
-func (r *Storage) RADDTOSET(key string, args []any) error {
+// LSPLIT moves elements of the array src starting from index into a new
+// array dst in O(log n). index may be negative to count from the end.
+// dst inherits expiration of src. It returns the length of dst.
+func (r *Storage) LSPLIT(src string, index int, dst string) (int, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if len(args) == 0 {
-		return errors.New("WrongArgs")
+	srcTrp, err := r.getArray(src)
+	if err != nil {
+		return 0, err
 	}
-
-	struct_kind := r.getStruct(key)
-	if struct_kind == kindScalar || struct_kind == kindMap {
-		return errors.New("KeyError: this key already exists and has different type")
+	if srcTrp == nil {
+		r.logger.Error("KeyError", zap.String("Key doesn't exist", src))
+		return 0, errors.New("KeyError")
 	}
-
-	if _, ok := r.innerArray[key]; !ok {
-		r.innerArray[key] = NewTreap()
-		r.innerExpire[key] = 0
+	if r.getLiveStruct(dst) != kindNoStruct {
+		return 0, errors.New("KeyError: destination key already exists")
 	}
 
-	if r.isExpired(key) {
-		r.deleteKey(key, kindArray)
-		return errors.New("KeyExpired")
+	size := srcTrp.GetSize()
+	if index < 0 {
+		index = max(size+index, 0)
 	}
+	index = min(index, size)
 
-	for _, arg := range args {
-		err := r.innerArray[key].PushBackToSet(arg)
-		if err != nil {
-			return err
-		}
+	dstTrp := srcTrp.SplitAt(index)
+	if dstTrp.GetSize() == 0 {
+		return 0, nil
+	}
+	r.innerArray[dst] = dstTrp
+	r.setKey(dst, kindArray)
+	r.innerExpire[dst] = r.innerExpire[src]
+	if srcTrp.GetSize() == 0 {
+		r.deleteKey(src, kindArray)
 	}
-	r.innerKeys[key] = kindArray
 
-	return nil
+	return dstTrp.GetSize(), nil
 }
 
-func (r *Storage) LPOP(key string, args []int) ([]any, error) {
+// LMOVE atomically pops an element from the left or right end of the array
+// src and pushes it to the left or right end of the array dst. It returns
+// the moved element or nil if src is empty.
+func (r *Storage) LMOVE(src string, dst string, fromLeft bool, toLeft bool) (*any, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if len(args) > 2 {
-		return nil, errors.New("WrongArgs")
-	}
+	return r.lmove(src, dst, fromLeft, toLeft)
+}
 
-	if r.isExpired(key) {
-		r.deleteKey(key, kindArray)
+func (r *Storage) lmove(src string, dst string, fromLeft bool, toLeft bool) (*any, error) {
+	srcTrp, err := r.getArray(src)
+	if err != nil || srcTrp == nil {
+		return nil, err
+	}
+	dstTrp, err := r.getArray(dst)
+	if err != nil {
+		return nil, err
+	}
+	// LPOP and RPOP may leave an empty array under the key.
+	if srcTrp.GetSize() == 0 {
+		return nil, nil
 	}
 
-	trp, ok := r.innerArray[key]
-	if !ok {
-		r.logger.Error("KeyError", zap.String("Key doesn't exist", key))
-		return nil, errors.New("KeyError")
+	var popped any
+	if fromLeft {
+		popped = srcTrp.PopFront()
+	} else {
+		popped = srcTrp.PopBack()
 	}
+	res := popped.(value).Val
 
-	rt, lf, err := trp.ValidateEraseSlice(args, true)
+	if dstTrp == nil {
+		dstTrp = NewTreap()
+		r.innerArray[dst] = dstTrp
+		r.setKey(dst, kindArray)
+		r.innerExpire[dst] = 0
+	}
+	if toLeft {
+		err = dstTrp.PushFront(res)
+	} else {
+		err = dstTrp.PushBack(res)
+	}
 	if err != nil {
 		return nil, err
 	}
-	nodes := trp.EraseSection(rt, lf)
+	r.notifyKey(dst)
 
-	return nodes, nil
+	if srcTrp.GetSize() == 0 {
+		r.deleteKey(src, kindArray)
+	}
+	return &res, nil
 }
 
-func (r *Storage) RPOP(key string, args []int) ([]any, error) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
-	if len(args) > 2 {
-		return nil, errors.New("WrongArgs")
-	}
+func (r *Storage) RPOPLPUSH(src string, dst string) (*any, error) {
+	return r.LMOVE(src, dst, false, true)
+}
 
-	if r.isExpired(key) {
-		r.deleteKey(key, kindArray)
+// notifyKey wakes up blocked pops waiting for elements by key.
+func (r *Storage) notifyKey(key string) {
+	for _, ch := range r.waiters[key] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
 	}
+	delete(r.waiters, key)
+}
 
-	trp, ok := r.innerArray[key]
-	if !ok {
-		r.logger.Error("KeyError", zap.String("Key doesn't exist", key))
-		return nil, errors.New("KeyError")
+func (r *Storage) removeWaiter(keys []string, ch chan struct{}) {
+	for _, key := range keys {
+		chans := slices.DeleteFunc(r.waiters[key], func(c chan struct{}) bool {
+			return c == ch
+		})
+		if len(chans) == 0 {
+			delete(r.waiters, key)
+		} else {
+			r.waiters[key] = chans
+		}
 	}
+}
 
-	rt, lf, err := trp.ValidateEraseSlice(args, false)
-	if err != nil {
-		return nil, err
-	}
+// BLPOP pops an element from the left end of the first non-empty array
+// among keys. If all of them are empty, it blocks until an element is pushed,
+// timeout expires or ctx is done. Zero timeout blocks without a limit.
+// It returns the key and the popped element or nil on timeout.
+func (r *Storage) BLPOP(ctx context.Context, keys []string, timeout time.Duration) (string, *any, error) {
+	return r.blockingPop(ctx, keys, timeout, true)
+}
 
-	nodes := trp.EraseSection(rt, lf)
-	slices.Reverse(nodes)
-	return nodes, nil
+// BRPOP is the same as BLPOP, but pops from the right end of the array.
+func (r *Storage) BRPOP(ctx context.Context, keys []string, timeout time.Duration) (string, *any, error) {
+	return r.blockingPop(ctx, keys, timeout, false)
 }
 
-func (r *Storage) LSET(key string, index int, val any) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+func (r *Storage) blockingPop(ctx context.Context, keys []string, timeout time.Duration, fromLeft bool) (string, *any, error) {
+	if len(keys) == 0 || timeout < 0 {
+		return "", nil, errors.New("WrongArgs")
+	}
 
-	if r.isExpired(key) {
-		r.deleteKey(key, kindArray)
-		return errors.New("KeyExpired")
+	var (
+		key string
+		res *any
+	)
+	err := r.blockOn(ctx, keys, timeout, func() (bool, error) {
+		var err error
+		key, res, err = r.popFirst(keys, fromLeft)
+		return res != nil, err
+	})
+	if err != nil || res == nil {
+		return "", nil, err
 	}
+	return key, res, nil
+}
 
-	trp, ok := r.innerArray[key]
-	if !ok {
-		r.logger.Error("KeyError", zap.String("Key doesn't exist", key))
-		return errors.New("KeyError")
+// blockOn calls try under the lock until it reports done. Between attempts
+// it waits until one of keys is notified, timeout expires or ctx is done.
+// Zero timeout waits without a limit. On timeout it returns nil.
+func (r *Storage) blockOn(ctx context.Context, keys []string, timeout time.Duration, try func() (bool, error)) error {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
 	}
-	if trp.Set(index, val) {
-		return nil
+
+	ch := make(chan struct{}, 1)
+	for {
+		r.mutex.Lock()
+		done, err := try()
+		if err != nil || done {
+			r.mutex.Unlock()
+			return err
+		}
+		for _, key := range keys {
+			r.waiters[key] = append(r.waiters[key], ch)
+		}
+		r.mutex.Unlock()
+
+		select {
+		case <-ch:
+			r.mutex.Lock()
+			r.removeWaiter(keys, ch)
+			r.mutex.Unlock()
+		case <-deadline:
+			r.mutex.Lock()
+			r.removeWaiter(keys, ch)
+			r.mutex.Unlock()
+			return nil
+		case <-ctx.Done():
+			r.mutex.Lock()
+			r.removeWaiter(keys, ch)
+			r.mutex.Unlock()
+			return ctx.Err()
+		}
 	}
+}
 
-	return errors.New("IndexOutOfRange")
+func (r *Storage) popFirst(keys []string, fromLeft bool) (string, *any, error) {
+	for _, key := range keys {
+		trp, err := r.getArray(key)
+		if err != nil {
+			return "", nil, err
+		}
+		if trp == nil || trp.GetSize() == 0 {
+			continue
+		}
+
+		var popped any
+		if fromLeft {
+			popped = trp.PopFront()
+		} else {
+			popped = trp.PopBack()
+		}
+		res := popped.(value).Val
+		if trp.GetSize() == 0 {
+			r.deleteKey(key, kindArray)
+		}
+		return key, &res, nil
+	}
+	return "", nil, nil
 }
 
-func (r *Storage) LGET(key string, index int) (any, error) {
+func (r *Storage) LSCAN(key string, cursor int, count int) (int, []any, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
 	if r.isExpired(key) {
 		r.deleteKey(key, kindArray)
-		return nil, errors.New("KeyExpired")
+		return 0, nil, errors.New("KeyExpired")
 	}
 
 	trp, ok := r.innerArray[key]
 	if !ok {
 		r.logger.Error("KeyError", zap.String("Key doesn't exist", key))
-		return nil, errors.New("KeyError")
+		return 0, nil, errors.New("KeyError")
 	}
 
-	ans, ok := trp.Get(index)
-	if !ok {
-		return nil, errors.New("IndexOutOfRange")
+	if cursor < 0 {
+		return 0, nil, errors.New("IndexOutOfRange")
+	}
+	if count <= 0 {
+		count = defaultScanCount
 	}
 
-	return ans, nil
+	res := make([]any, 0)
+	if cursor >= trp.GetSize() {
+		return 0, res, nil
+	}
+
+	last := cursor + min(count, trp.GetSize()-cursor) - 1
+	for _, val := range trp.Range(cursor, last) {
+		res = append(res, val.Val)
+	}
+
+	next := last + 1
+	if next == trp.GetSize() {
+		next = 0
+	}
+	return next, res, nil
 }
 
 func (r *Storage) Expire(key string, secs int64) int {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
+	return r.expire(key, secs)
+}
+
+func (r *Storage) expire(key string, secs int64) int {
 	valKind := r.getStruct(key)
 	if valKind == kindNoStruct {
 		return 0
@@ -484,11 +2035,35 @@ func (r *Storage) getState() StorageCondition {
 		inArr[k] = v.GetAllValues()
 	}
 
+	// The state is encoded after the mutex is released, so it must not
+	// share maps with the storage.
+	inMap := make(map[string]map[string]value, len(r.innerMap))
+	for k, v := range r.innerMap {
+		inMap[k] = maps.Clone(v)
+	}
+	inFieldExpire := make(map[string]map[string]int64, len(r.innerFieldExpire))
+	for k, v := range r.innerFieldExpire {
+		inFieldExpire[k] = maps.Clone(v)
+	}
+
+	inCMS, inTopK := r.getSketchState()
+
 	toIncode := StorageCondition{
-		InnerScalar: r.innerScalar,
-		InnerArray:  inArr,
-		InnerMap:    r.innerMap,
-		InnerExpire: r.innerExpire,
+		InnerScalar:      maps.Clone(r.innerScalar),
+		InnerArray:       inArr,
+		InnerMap:         inMap,
+		InnerExpire:      maps.Clone(r.innerExpire),
+		InnerFieldExpire: inFieldExpire,
+		InnerSet:         r.getSetState(),
+		InnerZSet:        r.getZSetState(),
+		InnerStream:      r.getStreamState(),
+		InnerBitmap:      r.getBitmapState(),
+		InnerHLL:         r.getHLLState(),
+		InnerBloom:       r.getBloomState(),
+		InnerCuckoo:      r.getCuckooState(),
+		InnerCMS:         inCMS,
+		InnerTopK:        inTopK,
+		InnerTimeSeries:  r.getTimeSeriesState(),
 	}
 	return toIncode
 }
@@ -506,7 +2081,7 @@ func (r *Storage) recoverFromCondition(state StorageCondition) {
 			delete(r.innerExpire, key)
 		} else {
 			tempExp := r.innerExpire[key]
-			r.SET(key, val.Val, 0)
+			r.set(key, val.Val, 0)
 			r.innerExpire[key] = tempExp
 		}
 	}
@@ -522,7 +2097,7 @@ func (r *Storage) recoverFromCondition(state StorageCondition) {
 		for _, val := range vals {
 			toPush = append(toPush, val.Val)
 		}
-		r.RPUSH(key, toPush)
+		r.rpush(key, toPush)
 		r.innerExpire[key] = tempExp
 	}
 
@@ -532,17 +2107,33 @@ func (r *Storage) recoverFromCondition(state StorageCondition) {
 			continue
 		}
 		tempExp := r.innerExpire[key]
+		fields := make(map[string]any, len(inHash))
 		for field, val := range inHash {
-			r.HSET(key, field, val.Val)
+			fields[field] = val.Val
 		}
+		r.hset(key, fields)
 		r.innerExpire[key] = tempExp
+
+		for field, expireAt := range state.InnerFieldExpire[key] {
+			if _, ok := r.innerFieldExpire[key]; !ok {
+				r.innerFieldExpire[key] = make(map[string]int64)
+			}
+			r.innerFieldExpire[key][field] = expireAt
+		}
+		r.expireFields(key)
 	}
+
+	r.recoverSets(state.InnerSet)
+	r.recoverZSets(state.InnerZSet)
+	r.recoverStreams(state.InnerStream)
+	r.recoverBitmaps(state.InnerBitmap)
+	r.recoverHLLs(state.InnerHLL)
+	r.recoverFilters(state.InnerBloom, state.InnerCuckoo)
+	r.recoverSketches(state.InnerCMS, state.InnerTopK)
+	r.recoverTimeSeries(state.InnerTimeSeries)
 }
 
 func (r *Storage) isExpired(key string) bool {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
 	expireAt := r.innerExpire[key]
 	if expireAt == 0 {
 		return false
@@ -551,9 +2142,6 @@ func (r *Storage) isExpired(key string) bool {
 }
 
 func (r *Storage) deleteKey(key string, valKind StructKind) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
 	switch valKind {
 	case kindScalar:
 		delete(r.innerScalar, key)
@@ -561,11 +2149,38 @@ func (r *Storage) deleteKey(key string, valKind StructKind) {
 		delete(r.innerArray, key)
 	case kindMap:
 		delete(r.innerMap, key)
+		delete(r.innerFieldExpire, key)
+	case kindSet:
+		delete(r.innerSet, key)
+	case kindZSet:
+		delete(r.innerZSet, key)
+	case kindStream:
+		delete(r.innerStream, key)
+	case kindBitmap:
+		delete(r.innerBitmap, key)
+	case kindHLL:
+		delete(r.innerHLL, key)
+	case kindBloom:
+		delete(r.innerBloom, key)
+	case kindCuckoo:
+		delete(r.innerCuckoo, key)
+	case kindCMS:
+		delete(r.innerCMS, key)
+	case kindTopK:
+		delete(r.innerTopK, key)
+	case kindTimeSeries:
+		r.unlinkTimeSeries(key)
+		delete(r.innerTimeSeries, key)
 	}
 	delete(r.innerKeys, key)
+	r.innerIndex.Delete(key)
 	delete(r.innerExpire, key)
 }
 
+func lessString(a, b string) bool {
+	return a < b
+}
+
 func isFloatInt(num any) bool {
 	return num.(float64) == math.Trunc(num.(float64))
 }
@@ -579,6 +2194,10 @@ func (r *Storage) garbageCollector() {
 			r.deleteKey(key, r.innerKeys[key])
 		}
 	}
+	for key := range r.innerFieldExpire {
+		r.expireFields(key)
+	}
+	r.trimTimeSeries()
 }
 
 func (r *Storage) startExpirationChecker(closeChan chan struct{}, tm time.Duration) {
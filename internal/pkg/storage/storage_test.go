@@ -1,8 +1,17 @@
 package storage
 
 import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 func TestGet(t *testing.T) {
@@ -73,3 +82,1698 @@ func BenchmarkPrivateGet(b *testing.B) {
 	}
 
 }
+
+func newTestStorage() *Storage {
+	return &Storage{
+		innerScalar:      make(map[string]value),
+		innerArray:       make(map[string]*Treap),
+		innerKeys:        make(map[string]StructKind),
+		innerIndex:       newOrderedTreap(lessString),
+		innerMap:         make(map[string]map[string]value),
+		innerSet:         make(map[string]valueSet),
+		innerZSet:        make(map[string]*sortedSet),
+		innerStream:      make(map[string]*stream),
+		innerBitmap:      make(map[string][]byte),
+		innerHLL:         make(map[string]hyperLogLog),
+		innerBloom:       make(map[string]*bloomFilter),
+		innerCuckoo:      make(map[string]*cuckooFilter),
+		innerCMS:         make(map[string]*countMinSketch),
+		innerTopK:        make(map[string]*topK),
+		innerTimeSeries:  make(map[string]*timeSeries),
+		innerExpire:      make(map[string]int64),
+		innerFieldExpire: make(map[string]map[string]int64),
+		waiters:          make(map[string][]chan struct{}),
+		mutex:            new(sync.RWMutex),
+		logger:           zap.NewNop(),
+	}
+}
+
+func TestDelExistsType(t *testing.T) {
+	s := newTestStorage()
+
+	s.SET("scalar", "val", 0)
+	s.HSET("hash", map[string]any{"field": 1})
+	s.RPUSH("array", []any{1, 2})
+
+	expectedKinds := map[string]StructKind{
+		"scalar":  kindScalar,
+		"hash":    kindMap,
+		"array":   kindArray,
+		"missing": kindNoStruct,
+	}
+	for key, expected := range expectedKinds {
+		if actual := s.TYPE(key); actual != expected {
+			t.Errorf("Wrong type by key %s. Actual: %s. Expected: %s", key, actual, expected)
+		}
+	}
+
+	if cnt := s.EXISTS([]string{"scalar", "hash", "missing", "array"}); cnt != 3 {
+		t.Errorf("Wrong exists count. Actual: %d. Expected: 3", cnt)
+	}
+	if cnt := s.DEL([]string{"scalar", "missing", "array"}); cnt != 2 {
+		t.Errorf("Wrong deleted count. Actual: %d. Expected: 2", cnt)
+	}
+	if s.GET("scalar") != nil {
+		t.Errorf("Get value for deleted key scalar")
+	}
+	if cnt := s.EXISTS([]string{"scalar", "hash", "array"}); cnt != 1 {
+		t.Errorf("Wrong exists count after delete. Actual: %d. Expected: 1", cnt)
+	}
+}
+
+func TestExpiredKeyIsMissing(t *testing.T) {
+	s := newTestStorage()
+
+	s.SET("key", "val", 0)
+	s.innerExpire["key"] = time.Now().Add(-time.Second).UnixMilli()
+
+	if s.EXISTS([]string{"key"}) != 0 {
+		t.Errorf("Expired key reported as existing")
+	}
+	if _, ok := s.innerKeys["key"]; ok {
+		t.Errorf("Expired key was not deleted")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	s := newTestStorage()
+
+	for _, k := range []string{"user:1", "user:2", "user:10", "order:1", "hello", "hallo", "hxllo"} {
+		s.SET(k, k, 0)
+	}
+
+	cases := map[string][]string{
+		"user:*":    {"user:1", "user:10", "user:2"},
+		"user:?":    {"user:1", "user:2"},
+		"h[ae]llo":  {"hallo", "hello"},
+		"h[^e]llo":  {"hallo", "hxllo"},
+		"h[a-b]llo": {"hallo"},
+		"*:1":       {"order:1", "user:1"},
+		"nothing*":  {},
+	}
+	for pattern, expected := range cases {
+		actual := s.KEYS(pattern)
+		if !slices.Equal(actual, expected) {
+			t.Errorf("Wrong keys by pattern %s. Actual: %v. Expected: %v", pattern, actual, expected)
+		}
+	}
+	if len(s.KEYS("")) != 7 {
+		t.Errorf("Empty pattern must match all keys")
+	}
+}
+
+func TestScan(t *testing.T) {
+	s := newTestStorage()
+
+	expected := make([]string, 0)
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("key:%02d", i)
+		s.SET(key, i, 0)
+		expected = append(expected, key)
+	}
+	s.HSET("hash", map[string]any{"field": 1})
+	s.SET("expired", 1, 0)
+	s.innerExpire["expired"] = time.Now().Add(-time.Second).UnixMilli()
+
+	actual := make([]string, 0)
+	cursor := ""
+	for {
+		next, keys := s.SCAN(cursor, "key:*", 7, kindScalar)
+		actual = append(actual, keys...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if !slices.Equal(actual, expected) {
+		t.Errorf("Wrong scanned keys. Actual: %v. Expected: %v", actual, expected)
+	}
+	if _, ok := s.innerKeys["expired"]; ok {
+		t.Errorf("Expired key was not deleted by scan")
+	}
+
+	if _, keys := s.SCAN("", "*", 100, kindMap); !slices.Equal(keys, []string{"hash"}) {
+		t.Errorf("Wrong scanned keys by type. Actual: %v", keys)
+	}
+}
+
+func TestHScanLScan(t *testing.T) {
+	s := newTestStorage()
+
+	for i := 0; i < 12; i++ {
+		s.HSET("hash", map[string]any{strconv.Itoa(i): i})
+		s.RPUSH("array", []any{i})
+	}
+
+	fields := make(map[string]any)
+	cursor := ""
+	for {
+		next, page, err := s.HSCAN("hash", cursor, "", 5)
+		if err != nil {
+			t.Fatalf("HSCAN error: %s", err)
+		}
+		for k, v := range page {
+			fields[k] = v
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if len(fields) != 12 {
+		t.Errorf("Wrong fields count. Actual: %d. Expected: 12", len(fields))
+	}
+
+	vals := make([]any, 0)
+	idx := 0
+	for {
+		next, page, err := s.LSCAN("array", idx, 5)
+		if err != nil {
+			t.Fatalf("LSCAN error: %s", err)
+		}
+		vals = append(vals, page...)
+		if next == 0 {
+			break
+		}
+		idx = next
+	}
+	for i, v := range vals {
+		if v != i {
+			t.Errorf("Wrong element by index %d. Actual: %v", i, v)
+		}
+	}
+	if len(vals) != 12 {
+		t.Errorf("Wrong elements count. Actual: %d. Expected: 12", len(vals))
+	}
+
+	if next, page, _ := s.HSCAN("hash", "", "", math.MaxInt); next != "" || len(page) != 12 {
+		t.Errorf("Wrong HSCAN with huge count: %q, %d fields", next, len(page))
+	}
+	if next, page, _ := s.LSCAN("array", 1, math.MaxInt); next != 0 || len(page) != 11 {
+		t.Errorf("Wrong LSCAN with huge count: %d, %d elements", next, len(page))
+	}
+
+	if sKind, ok := ParseStructKind("Map"); !ok || sKind != kindMap {
+		t.Errorf("Wrong kind parsed: %s", sKind)
+	}
+	if _, ok := ParseStructKind("hash"); ok {
+		t.Errorf("Unknown kind was parsed")
+	}
+}
+
+func TestIncrDecr(t *testing.T) {
+	s := newTestStorage()
+
+	s.SET("counter", float64(10), 0)
+	expire := time.Now().Add(time.Hour).UnixMilli()
+	s.innerExpire["counter"] = expire
+
+	steps := []struct {
+		op       func() (int, error)
+		expected int
+	}{
+		{func() (int, error) { return s.INCR("counter") }, 11},
+		{func() (int, error) { return s.INCRBY("counter", 9) }, 20},
+		{func() (int, error) { return s.DECR("counter") }, 19},
+		{func() (int, error) { return s.DECRBY("counter", 20) }, -1},
+		{func() (int, error) { return s.INCR("new") }, 1},
+		{func() (int, error) { return s.HINCRBY("hash", "field", 5) }, 5},
+		{func() (int, error) { return s.HINCRBY("hash", "field", -2) }, 3},
+	}
+	for i, step := range steps {
+		actual, err := step.op()
+		if err != nil || actual != step.expected {
+			t.Errorf("Wrong counter on step %d. Actual: %d (%v). Expected: %d", i, actual, err, step.expected)
+		}
+	}
+	if s.innerExpire["counter"] != expire {
+		t.Errorf("Counter lost its expiration")
+	}
+
+	s.SET("str", "abc", 0)
+	if _, err := s.INCR("str"); err == nil {
+		t.Errorf("Incremented string value")
+	}
+	s.HSET("hash", map[string]any{"str": "abc"})
+	if _, err := s.HINCRBY("hash", "str", 1); err == nil {
+		t.Errorf("Incremented string hash field")
+	}
+	if _, err := s.HINCRBY("counter", "field", 1); err == nil {
+		t.Errorf("Incremented hash field of scalar key")
+	}
+	s.SET("max", math.MaxInt, 0)
+	if _, err := s.INCR("max"); err == nil {
+		t.Errorf("Increment overflow was not detected")
+	}
+}
+
+func TestStringCommands(t *testing.T) {
+	s := newTestStorage()
+
+	s.SET("key", "Hello", 0)
+	expire := time.Now().Add(time.Hour).UnixMilli()
+	s.innerExpire["key"] = expire
+
+	if n, _ := s.APPEND("key", " World"); n != 11 {
+		t.Errorf("Wrong length after append. Actual: %d. Expected: 11", n)
+	}
+	if n, _ := s.STRLEN("key"); n != 11 {
+		t.Errorf("Wrong strlen. Actual: %d. Expected: 11", n)
+	}
+	ranges := map[[2]int]string{
+		{0, 4}:    "Hello",
+		{-5, -1}:  "World",
+		{6, 100}:  "World",
+		{-100, 1}: "He",
+		{5, 2}:    "",
+	}
+	for rng, expected := range ranges {
+		if actual, _ := s.GETRANGE("key", rng[0], rng[1]); actual != expected {
+			t.Errorf("Wrong range %v. Actual: %q. Expected: %q", rng, actual, expected)
+		}
+	}
+	if n, _ := s.SETRANGE("key", 6, "Redis"); n != 11 || *s.GET("key") != "Hello Redis" {
+		t.Errorf("Wrong value after setrange: %v", *s.GET("key"))
+	}
+	if n, _ := s.SETRANGE("pad", 2, "ab"); n != 4 || *s.GET("pad") != "\x00\x00ab" {
+		t.Errorf("Wrong padded value after setrange: %q", *s.GET("pad"))
+	}
+	if _, err := s.SETRANGE("pad", math.MaxInt-1, "ab"); err == nil {
+		t.Errorf("SETRANGE accepted offset beyond maximum size")
+	}
+	if *s.GET("pad") != "\x00\x00ab" {
+		t.Errorf("Failed SETRANGE changed value")
+	}
+	if s.innerExpire["key"] != expire {
+		t.Errorf("String commands changed expiration")
+	}
+
+	s.SET("num", 12, 0)
+	if n, _ := s.APPEND("num", "3"); n != 3 || *s.GET("num") != "123" {
+		t.Errorf("Wrong value after append to number: %v", *s.GET("num"))
+	}
+
+	if old, _ := s.GETSET("key", 5); old == nil || *old != "Hello Redis" || *s.GET("key") != 5 {
+		t.Errorf("Wrong getset result")
+	}
+	if s.innerExpire["key"] != expire {
+		t.Errorf("GETSET changed expiration")
+	}
+	if old, _ := s.GETEX("key", 0, true); old == nil || s.innerExpire["key"] != 0 {
+		t.Errorf("GETEX did not persist key")
+	}
+	if old, _ := s.GETEX("key", 100, false); old == nil || s.innerExpire["key"] == 0 {
+		t.Errorf("GETEX did not set expiration")
+	}
+	if old, _ := s.GETDEL("key"); old == nil || *old != 5 || s.GET("key") != nil {
+		t.Errorf("Wrong getdel result")
+	}
+	if old, err := s.GETDEL("key"); old != nil || err != nil {
+		t.Errorf("GETDEL returned value for missing key")
+	}
+
+	s.RPUSH("array", []any{1})
+	if _, err := s.APPEND("array", "x"); err == nil {
+		t.Errorf("Appended to array key")
+	}
+}
+
+func TestConditionalSet(t *testing.T) {
+	s := newTestStorage()
+
+	if written, _, _ := s.SET("key", "first", 0, SetXX()); written {
+		t.Errorf("XX wrote missing key")
+	}
+	if written, _, _ := s.SET("key", "first", 0, SetNX()); !written {
+		t.Errorf("NX did not write missing key")
+	}
+	if written, old, _ := s.SET("key", "second", 0, SetNX(), SetGet()); written || old == nil || *old != "first" {
+		t.Errorf("NX overwrote existing key")
+	}
+
+	expire := time.Now().Add(time.Hour).UnixMilli()
+	s.innerExpire["key"] = expire
+	if written, old, _ := s.SET("key", "third", 0, SetXX(), SetGet(), SetKeepTTL()); !written || *old != "first" {
+		t.Errorf("XX did not write existing key")
+	}
+	if s.innerExpire["key"] != expire || *s.GET("key") != "third" {
+		t.Errorf("KEEPTTL lost expiration")
+	}
+	if s.SET("key", "fourth", 0); s.innerExpire["key"] != 0 {
+		t.Errorf("Plain SET kept expiration")
+	}
+
+	if _, _, err := s.SET("key", "val", 0, SetNX(), SetXX()); err == nil {
+		t.Errorf("NX and XX accepted together")
+	}
+	if _, _, err := s.SET("key", "val", 10, SetKeepTTL()); err == nil {
+		t.Errorf("EX and KEEPTTL accepted together")
+	}
+}
+
+func TestMultiKey(t *testing.T) {
+	s := newTestStorage()
+
+	if err := s.MSET(map[string]any{"a": 1, "b": "two"}); err != nil {
+		t.Fatalf("MSET error: %s", err)
+	}
+	s.HSET("hash", map[string]any{"field": 1})
+
+	vals := s.MGET([]string{"a", "missing", "b", "hash"})
+	if len(vals) != 4 || *vals[0] != 1 || vals[1] != nil || *vals[2] != "two" || vals[3] != nil {
+		t.Errorf("Wrong MGET result: %v", vals)
+	}
+
+	if err := s.MSET(map[string]any{"c": 1, "hash": 2}); err == nil || s.GET("c") != nil {
+		t.Errorf("MSET partially applied with wrong type key")
+	}
+	if err := s.MSET(map[string]any{"c": 1, "d": 1.5}); err == nil || s.GET("c") != nil {
+		t.Errorf("MSET partially applied with wrong value")
+	}
+
+	if ok, _ := s.MSETNX(map[string]any{"c": 1, "a": 2}); ok || s.GET("c") != nil || *s.GET("a") != 1 {
+		t.Errorf("MSETNX wrote while key exists")
+	}
+	if ok, _ := s.MSETNX(map[string]any{"c": 3, "d": 4}); !ok || *s.GET("c") != 3 || *s.GET("d") != 4 {
+		t.Errorf("MSETNX did not write new keys")
+	}
+	s.HSET("hash", map[string]any{"f": 1})
+	if ok, err := s.MSETNX(map[string]any{"e": 5, "hash": 6}); ok || err != nil || s.GET("e") != nil {
+		t.Errorf("MSETNX over hash key: %v, %v", ok, err)
+	}
+}
+
+func TestHashCommands(t *testing.T) {
+	s := newTestStorage()
+
+	if created, _ := s.HSET("hash", map[string]any{"a": 1, "b": "two"}); created != 2 {
+		t.Errorf("Wrong created count. Actual: %d. Expected: 2", created)
+	}
+	expire := time.Now().Add(time.Hour).UnixMilli()
+	s.innerExpire["hash"] = expire
+	if created, _ := s.HSET("hash", map[string]any{"b": 2, "c": 3}); created != 1 {
+		t.Errorf("Wrong created count. Actual: %d. Expected: 1", created)
+	}
+	if s.innerExpire["hash"] != expire {
+		t.Errorf("HSET reset expiration of existing hash")
+	}
+	if ok, _ := s.HSETNX("hash", "a", 100); ok || *s.HGET("hash", "a") != 1 {
+		t.Errorf("HSETNX overwrote existing field")
+	}
+	if ok, _ := s.HSETNX("hash", "d", 4); !ok {
+		t.Errorf("HSETNX did not create field")
+	}
+
+	if keys, _ := s.HKEYS("hash"); !slices.Equal(keys, []string{"a", "b", "c", "d"}) {
+		t.Errorf("Wrong HKEYS: %v", keys)
+	}
+	if vals, _ := s.HVALS("hash"); !slices.Equal(vals, []any{1, 2, 3, 4}) {
+		t.Errorf("Wrong HVALS: %v", vals)
+	}
+	if all, _ := s.HGETALL("hash"); len(all) != 4 || all["c"] != 3 {
+		t.Errorf("Wrong HGETALL: %v", all)
+	}
+	if ok, _ := s.HEXISTS("hash", "c"); !ok {
+		t.Errorf("HEXISTS did not find field")
+	}
+
+	if deleted, _ := s.HDEL("hash", []string{"a", "missing", "b"}); deleted != 2 {
+		t.Errorf("Wrong deleted count. Actual: %d. Expected: 2", deleted)
+	}
+	if n, _ := s.HLEN("hash"); n != 2 {
+		t.Errorf("Wrong HLEN. Actual: %d. Expected: 2", n)
+	}
+	s.HDEL("hash", []string{"c", "d"})
+	if s.TYPE("hash") != kindNoStruct {
+		t.Errorf("Empty hash was not deleted")
+	}
+
+	s.SET("scalar", 1, 0)
+	if _, err := s.HGETALL("scalar"); err == nil {
+		t.Errorf("HGETALL of scalar key")
+	}
+	if _, err := s.HSET("scalar", map[string]any{"a": 1}); err == nil {
+		t.Errorf("HSET of scalar key")
+	}
+}
+
+func TestHashFieldExpire(t *testing.T) {
+	s := newTestStorage()
+
+	s.HSET("hash", map[string]any{"a": 1, "b": 2, "c": 3})
+
+	if codes, _ := s.HEXPIRE("hash", []string{"a", "b", "missing"}, 100); !slices.Equal(codes, []int{1, 1, -2}) {
+		t.Errorf("Wrong HEXPIRE codes: %v", codes)
+	}
+	if ttls, _ := s.HTTL("hash", []string{"a", "c", "missing"}); ttls[0] != 100 || ttls[1] != -1 || ttls[2] != -2 {
+		t.Errorf("Wrong HTTL: %v", ttls)
+	}
+	if codes, _ := s.HPERSIST("hash", []string{"b", "c"}); !slices.Equal(codes, []int{1, -1}) {
+		t.Errorf("Wrong HPERSIST codes: %v", codes)
+	}
+
+	s.innerFieldExpire["hash"]["a"] = time.Now().Add(-time.Second).UnixMilli()
+	if s.HGET("hash", "a") != nil {
+		t.Errorf("HGET returned expired field")
+	}
+	if all, _ := s.HGETALL("hash"); len(all) != 2 {
+		t.Errorf("HGETALL returned expired field: %v", all)
+	}
+
+	s.HEXPIRE("hash", []string{"b", "c"}, 100)
+	state := s.getState()
+	restored := newTestStorage()
+	restored.recoverFromCondition(state)
+	if ttls, _ := restored.HTTL("hash", []string{"b", "c"}); ttls[0] != 100 || ttls[1] != 100 {
+		t.Errorf("Field expiration was not restored: %v", ttls)
+	}
+
+	for field := range s.innerFieldExpire["hash"] {
+		s.innerFieldExpire["hash"][field] = time.Now().Add(-time.Second).UnixMilli()
+	}
+	s.garbageCollector()
+	if _, ok := s.innerMap["hash"]; ok {
+		t.Errorf("Garbage collector did not reclaim expired fields")
+	}
+}
+
+func TestLRangeLLen(t *testing.T) {
+	s := newTestStorage()
+
+	s.RPUSH("array", []any{0, 1, 2, 3, 4, 5})
+
+	cases := []struct {
+		start, stop int
+		expected    []any
+	}{
+		{0, -1, []any{0, 1, 2, 3, 4, 5}},
+		{1, 3, []any{1, 2, 3}},
+		{-3, -2, []any{3, 4}},
+		{-100, 1, []any{0, 1}},
+		{4, 100, []any{4, 5}},
+		{3, 1, []any{}},
+		{10, 20, []any{}},
+	}
+	for _, c := range cases {
+		if actual, _ := s.LRANGE("array", c.start, c.stop); !slices.Equal(actual, c.expected) {
+			t.Errorf("Wrong range [%d, %d]. Actual: %v. Expected: %v", c.start, c.stop, actual, c.expected)
+		}
+	}
+	if n, _ := s.LLEN("array"); n != 6 {
+		t.Errorf("LRANGE changed array length. Actual: %d. Expected: 6", n)
+	}
+	if n, _ := s.LLEN("missing"); n != 0 {
+		t.Errorf("Wrong length of missing array: %d", n)
+	}
+
+	s.SET("scalar", 1, 0)
+	if _, err := s.LRANGE("scalar", 0, -1); err == nil {
+		t.Errorf("LRANGE of scalar key")
+	}
+}
+
+func TestLInsertLRemLTrimLPos(t *testing.T) {
+	s := newTestStorage()
+
+	s.RPUSH("array", []any{"a", "b", "c", "b", "d", "b"})
+
+	if n, _ := s.LINSERT("array", true, "b", "x"); n != 7 {
+		t.Errorf("Wrong length after LINSERT. Actual: %d. Expected: 7", n)
+	}
+	if n, _ := s.LINSERT("array", false, "d", "y"); n != 8 {
+		t.Errorf("Wrong length after LINSERT. Actual: %d. Expected: 8", n)
+	}
+	if n, _ := s.LINSERT("array", false, "missing", "y"); n != -1 {
+		t.Errorf("LINSERT found missing pivot")
+	}
+	if vals, _ := s.LRANGE("array", 0, -1); !slices.Equal(vals, []any{"a", "x", "b", "c", "b", "d", "y", "b"}) {
+		t.Errorf("Wrong array after LINSERT: %v", vals)
+	}
+
+	posCases := []struct {
+		rank, count int
+		expected    []int
+	}{
+		{1, 0, []int{2, 4, 7}},
+		{2, 1, []int{4}},
+		{-1, 2, []int{7, 4}},
+		{4, 0, []int{}},
+	}
+	for _, c := range posCases {
+		if pos, _ := s.LPOS("array", "b", c.rank, c.count); !slices.Equal(pos, c.expected) {
+			t.Errorf("Wrong LPOS rank %d count %d. Actual: %v. Expected: %v", c.rank, c.count, pos, c.expected)
+		}
+	}
+
+	if n, _ := s.LREM("array", -2, "b"); n != 2 {
+		t.Errorf("Wrong LREM count. Actual: %d. Expected: 2", n)
+	}
+	if vals, _ := s.LRANGE("array", 0, -1); !slices.Equal(vals, []any{"a", "x", "b", "c", "d", "y"}) {
+		t.Errorf("Wrong array after LREM: %v", vals)
+	}
+	if n, _ := s.LREM("array", 0, "missing"); n != 0 {
+		t.Errorf("LREM removed missing value")
+	}
+
+	s.LTRIM("array", 1, -2)
+	if vals, _ := s.LRANGE("array", 0, -1); !slices.Equal(vals, []any{"x", "b", "c", "d"}) {
+		t.Errorf("Wrong array after LTRIM: %v", vals)
+	}
+	if pos, _ := s.LPOS("array", "a", 1, 0); len(pos) != 0 {
+		t.Errorf("Trimmed value is still counted")
+	}
+
+	s.RPUSH("nums", []any{float64(1), 2, float64(1)})
+	if n, _ := s.LREM("nums", 0, 1); n != 2 {
+		t.Errorf("LREM did not match numbers decoded from json")
+	}
+
+	s.LTRIM("array", 5, 10)
+	if s.TYPE("array") != kindNoStruct {
+		t.Errorf("LTRIM with empty range did not delete array")
+	}
+}
+
+func TestLReverseLRotate(t *testing.T) {
+	s := newTestStorage()
+
+	s.RPUSH("array", []any{0, 1, 2, 3, 4, 5, 6})
+
+	s.LREVERSE("array", 0, -1)
+	if vals, _ := s.LRANGE("array", 0, -1); !slices.Equal(vals, []any{6, 5, 4, 3, 2, 1, 0}) {
+		t.Errorf("Wrong array after LREVERSE: %v", vals)
+	}
+	s.LREVERSE("array", 1, 3)
+	if vals, _ := s.LRANGE("array", 0, -1); !slices.Equal(vals, []any{6, 3, 4, 5, 2, 1, 0}) {
+		t.Errorf("Wrong array after partial LREVERSE: %v", vals)
+	}
+	s.LREVERSE("array", -3, -1)
+	s.LREVERSE("array", 2, 5)
+	if vals, _ := s.LRANGE("array", 0, -1); !slices.Equal(vals, []any{6, 3, 1, 0, 5, 4, 2}) {
+		t.Errorf("Wrong array after nested LREVERSE: %v", vals)
+	}
+
+	s.LROTATE("array", 2)
+	if vals, _ := s.LRANGE("array", 0, -1); !slices.Equal(vals, []any{4, 2, 6, 3, 1, 0, 5}) {
+		t.Errorf("Wrong array after LROTATE: %v", vals)
+	}
+	s.LROTATE("array", -9)
+	if vals, _ := s.LRANGE("array", 0, -1); !slices.Equal(vals, []any{6, 3, 1, 0, 5, 4, 2}) {
+		t.Errorf("Wrong array after negative LROTATE: %v", vals)
+	}
+
+	if val, _ := s.LGET("array", 1); val != 3 {
+		t.Errorf("Wrong LGET after reverse. Actual: %v. Expected: 3", val)
+	}
+	if vals, _ := s.LPOP("array", []int{2}); !slices.Equal(vals, []any{6, 3}) {
+		t.Errorf("Wrong LPOP after reverse: %v", vals)
+	}
+	if pos, _ := s.LPOS("array", 4, 1, 0); !slices.Equal(pos, []int{3}) {
+		t.Errorf("Wrong LPOS after reverse: %v", pos)
+	}
+}
+
+func TestArrayAggregates(t *testing.T) {
+	s := newTestStorage()
+
+	s.RPUSH("array", []any{5, "skip", -3, 10, float64(7), "x", 2})
+
+	if sum, _ := s.LSUM("array", 0, -1); sum != 21 {
+		t.Errorf("Wrong LSUM. Actual: %d. Expected: 21", sum)
+	}
+	if sum, _ := s.LSUM("array", 2, 4); sum != 14 {
+		t.Errorf("Wrong LSUM of range. Actual: %d. Expected: 14", sum)
+	}
+	if mn, _ := s.LMIN("array", 0, -1); mn != -3 {
+		t.Errorf("Wrong LMIN. Actual: %d. Expected: -3", mn)
+	}
+	if mx, _ := s.LMAX("array", -3, -1); mx != 7 {
+		t.Errorf("Wrong LMAX of range. Actual: %d. Expected: 7", mx)
+	}
+	if cnt, _ := s.LCOUNT("array", 0, -1); cnt != 5 {
+		t.Errorf("Wrong LCOUNT. Actual: %d. Expected: 5", cnt)
+	}
+	if _, err := s.LMIN("array", 5, 5); err == nil {
+		t.Errorf("LMIN of range without integers")
+	}
+
+	s.LSET("array", 3, 100)
+	s.LREVERSE("array", 0, 3)
+	s.RPOP("array", []int{1})
+	if mx, _ := s.LMAX("array", 0, -1); mx != 100 {
+		t.Errorf("Wrong LMAX after updates. Actual: %d. Expected: 100", mx)
+	}
+	if sum, _ := s.LSUM("array", 0, -1); sum != 109 {
+		t.Errorf("Wrong LSUM after updates. Actual: %d. Expected: 109", sum)
+	}
+}
+
+func TestArrayRangeUpdates(t *testing.T) {
+	s := newTestStorage()
+
+	s.RPUSH("array", []any{1, 2, 3, 4, 5, 6})
+
+	s.LINCRRANGE("array", 1, 4, 10)
+	if vals, _ := s.LRANGE("array", 0, -1); !slices.Equal(vals, []any{1, 12, 13, 14, 15, 6}) {
+		t.Errorf("Wrong array after LINCRRANGE: %v", vals)
+	}
+	s.LMULRANGE("array", 0, 2, -2)
+	s.LREVERSE("array", 0, -1)
+	s.LINCRRANGE("array", -2, -1, 1)
+	expected := []any{6, 15, 14, -26, -23, -1}
+	if vals, _ := s.LRANGE("array", 0, -1); !slices.Equal(vals, expected) {
+		t.Errorf("Wrong array after combined updates: %v. Expected: %v", vals, expected)
+	}
+	if sum, _ := s.LSUM("array", 0, -1); sum != -15 {
+		t.Errorf("Wrong LSUM after updates. Actual: %d. Expected: -15", sum)
+	}
+	if mn, _ := s.LMIN("array", 0, -1); mn != -26 {
+		t.Errorf("Wrong LMIN after updates. Actual: %d. Expected: -26", mn)
+	}
+	if mx, _ := s.LMAX("array", 2, -1); mx != 14 {
+		t.Errorf("Wrong LMAX after updates. Actual: %d. Expected: 14", mx)
+	}
+
+	if pos, _ := s.LPOS("array", -26, 1, 0); !slices.Equal(pos, []int{3}) {
+		t.Errorf("Value counts were not updated: %v", pos)
+	}
+	if n, _ := s.LREM("array", 0, 13); n != 0 {
+		t.Errorf("LREM removed stale value")
+	}
+	s.RADDTOSET("array", []any{14, 100})
+	if n, _ := s.LLEN("array"); n != 7 {
+		t.Errorf("RADDTOSET used stale value counts. Length: %d", n)
+	}
+
+	s.RPUSH("mixed", []any{1, "a", 2})
+	if err := s.LINCRRANGE("mixed", 0, -1, 1); err == nil {
+		t.Errorf("LINCRRANGE updated range with strings")
+	}
+	if vals, _ := s.LRANGE("mixed", 0, -1); !slices.Equal(vals, []any{1, "a", 2}) {
+		t.Errorf("Failed LINCRRANGE changed values: %v", vals)
+	}
+
+	s.RPUSH("big", []any{2, math.MaxInt - 1, -5})
+	if err := s.LINCRRANGE("big", 0, 1, 10); err == nil {
+		t.Errorf("LINCRRANGE did not detect overflow")
+	}
+	if err := s.LMULRANGE("big", 1, 2, -2); err == nil {
+		t.Errorf("LMULRANGE did not detect overflow")
+	}
+	if vals, _ := s.LRANGE("big", 0, -1); !slices.Equal(vals, []any{2, math.MaxInt - 1, -5}) {
+		t.Errorf("Overflowing update changed values: %v", vals)
+	}
+	if _, err := s.LSUM("big", 0, 1); err == nil {
+		t.Errorf("LSUM did not detect overflow")
+	}
+	if sum, err := s.LSUM("big", 1, 2); sum != math.MaxInt-6 || err != nil {
+		t.Errorf("Wrong LSUM near overflow: %d, %v", sum, err)
+	}
+	s.LMULRANGE("big", 0, -1, 0)
+	if sum, err := s.LSUM("big", 0, -1); sum != 0 || err != nil {
+		t.Errorf("Wrong LSUM after reset: %d, %v", sum, err)
+	}
+}
+
+func TestLConcatLSplit(t *testing.T) {
+	s := newTestStorage()
+
+	s.RPUSH("dst", []any{1, 2, 3})
+	s.RPUSH("src", []any{3, 4, "a"})
+
+	if n, _ := s.LCONCAT("dst", "src"); n != 6 {
+		t.Errorf("Wrong length after LCONCAT. Actual: %d. Expected: 6", n)
+	}
+	if s.TYPE("src") != kindNoStruct {
+		t.Errorf("LCONCAT did not delete source")
+	}
+	if pos, _ := s.LPOS("dst", 3, 1, 0); !slices.Equal(pos, []int{2, 3}) {
+		t.Errorf("Wrong value counts after LCONCAT: %v", pos)
+	}
+
+	if n, _ := s.LSPLIT("dst", -2, "tail"); n != 2 {
+		t.Errorf("Wrong length after LSPLIT. Actual: %d. Expected: 2", n)
+	}
+	if vals, _ := s.LRANGE("dst", 0, -1); !slices.Equal(vals, []any{1, 2, 3, 3}) {
+		t.Errorf("Wrong source after LSPLIT: %v", vals)
+	}
+	if vals, _ := s.LRANGE("tail", 0, -1); !slices.Equal(vals, []any{4, "a"}) {
+		t.Errorf("Wrong destination after LSPLIT: %v", vals)
+	}
+	if pos, _ := s.LPOS("dst", 4, 1, 0); len(pos) != 0 {
+		t.Errorf("Split value is still counted in source")
+	}
+	s.RADDTOSET("tail", []any{"a", 5})
+	if n, _ := s.LLEN("tail"); n != 3 {
+		t.Errorf("Wrong value counts in split destination. Length: %d", n)
+	}
+
+	if _, err := s.LSPLIT("dst", 1, "tail"); err == nil {
+		t.Errorf("LSPLIT overwrote existing key")
+	}
+	if n, _ := s.LSPLIT("dst", 0, "all"); n != 4 || s.TYPE("dst") != kindNoStruct {
+		t.Errorf("LSPLIT from index 0 did not move the whole array")
+	}
+
+	s.RPUSH("long", []any{1, 2, 1, 3, 1, 4})
+	s.LSPLIT("long", 1, "long:tail")
+	s.LSPLIT("long:tail", -1, "long:last")
+	for key, want := range map[string]map[value]int{
+		"long":      {{Val: 1, Kin: kindInt}: 1},
+		"long:tail": {{Val: 1, Kin: kindInt}: 2, {Val: 2, Kin: kindInt}: 1, {Val: 3, Kin: kindInt}: 1},
+		"long:last": {{Val: 4, Kin: kindInt}: 1},
+	} {
+		trp := s.innerArray[key]
+		if trp.mpDirty || !maps.Equal(trp.mp, want) {
+			t.Errorf("Value counts of %s were not moved: %v", key, trp.mp)
+		}
+	}
+}
+
+func TestLMove(t *testing.T) {
+	s := newTestStorage()
+
+	s.RPUSH("pending", []any{"job1", "job2", "job3"})
+
+	if val, _ := s.LMOVE("pending", "progress", true, false); val == nil || *val != "job1" {
+		t.Errorf("Wrong moved value: %v", val)
+	}
+	if val, _ := s.RPOPLPUSH("pending", "progress"); val == nil || *val != "job3" {
+		t.Errorf("Wrong moved value: %v", val)
+	}
+	if vals, _ := s.LRANGE("progress", 0, -1); !slices.Equal(vals, []any{"job3", "job1"}) {
+		t.Errorf("Wrong destination after LMOVE: %v", vals)
+	}
+	if vals, _ := s.LRANGE("pending", 0, -1); !slices.Equal(vals, []any{"job2"}) {
+		t.Errorf("Wrong source after LMOVE: %v", vals)
+	}
+
+	s.LMOVE("progress", "progress", true, false)
+	if vals, _ := s.LRANGE("progress", 0, -1); !slices.Equal(vals, []any{"job1", "job3"}) {
+		t.Errorf("Wrong rotation with LMOVE: %v", vals)
+	}
+
+	s.SET("scalar", 1, 0)
+	if _, err := s.LMOVE("pending", "scalar", true, true); err == nil {
+		t.Errorf("LMOVE pushed to scalar key")
+	}
+	if n, _ := s.LLEN("pending"); n != 1 {
+		t.Errorf("Failed LMOVE lost element")
+	}
+
+	s.LMOVE("pending", "progress", true, true)
+	if s.TYPE("pending") != kindNoStruct {
+		t.Errorf("Empty source was not deleted")
+	}
+	if val, err := s.LMOVE("pending", "progress", true, true); val != nil || err != nil {
+		t.Errorf("LMOVE from missing array returned %v, %v", val, err)
+	}
+
+	s.RPUSH("drained", []any{1})
+	s.LPOP("drained", nil)
+	if val, err := s.LMOVE("drained", "progress", true, true); val != nil || err != nil {
+		t.Errorf("LMOVE from empty array returned %v, %v", val, err)
+	}
+}
+
+func TestBlockingPop(t *testing.T) {
+	s := newTestStorage()
+
+	s.RPUSH("second", []any{"ready"})
+	if key, val, _ := s.BLPOP(context.Background(), []string{"first", "second"}, time.Second); key != "second" || *val != "ready" {
+		t.Errorf("BLPOP did not pop available element: %s %v", key, val)
+	}
+
+	type popResult struct {
+		key string
+		val *any
+	}
+	done := make(chan popResult)
+	go func() {
+		key, val, _ := s.BRPOP(context.Background(), []string{"first", "second"}, 5*time.Second)
+		done <- popResult{key, val}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	s.LPUSH("first", []any{"a", "b"})
+
+	select {
+	case res := <-done:
+		if res.key != "first" || res.val == nil || *res.val != "a" {
+			t.Errorf("Wrong BRPOP result: %s %v", res.key, res.val)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("BRPOP was not woken up by push")
+	}
+
+	if _, val, err := s.BLPOP(context.Background(), []string{"empty"}, 50*time.Millisecond); val != nil || err != nil {
+		t.Errorf("BLPOP did not time out: %v %v", val, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	if _, _, err := s.BLPOP(ctx, []string{"empty"}, 0); err == nil {
+		t.Errorf("BLPOP ignored context cancellation")
+	}
+	if len(s.waiters) != 0 {
+		t.Errorf("Waiters were not cleaned up: %v", s.waiters)
+	}
+}
+
+func TestSetCommands(t *testing.T) {
+	s := newTestStorage()
+
+	if added, _ := s.SADD("a", []any{1, 2, "x", 2}); added != 3 {
+		t.Errorf("Wrong SADD count: %d", added)
+	}
+	if added, _ := s.SADD("a", []any{"x", "y"}); added != 1 {
+		t.Errorf("SADD counted existing member: %d", added)
+	}
+	if members, _ := s.SMEMBERS("a"); !slices.Equal(members, []any{1, 2, "x", "y"}) {
+		t.Errorf("Wrong SMEMBERS: %v", members)
+	}
+	if ok, _ := s.SISMEMBER("a", 2); !ok {
+		t.Errorf("SISMEMBER did not find member")
+	}
+	if ok, _ := s.SISMEMBER("a", "2"); ok {
+		t.Errorf("SISMEMBER mixed up int and string")
+	}
+	if s.TYPE("a") != kindSet {
+		t.Errorf("Wrong TYPE of set: %s", s.TYPE("a"))
+	}
+	if _, err := s.LLEN("a"); err == nil {
+		t.Errorf("Array command accepted set key")
+	}
+	if _, err := s.SADD("a", []any{1.5}); err == nil {
+		t.Errorf("SADD accepted float member")
+	}
+
+	s.SADD("b", []any{2, "y", "z"})
+	if res, _ := s.SUNION([]string{"a", "b"}); !slices.Equal(res, []any{1, 2, "x", "y", "z"}) {
+		t.Errorf("Wrong SUNION: %v", res)
+	}
+	if res, _ := s.SINTER([]string{"a", "b"}); !slices.Equal(res, []any{2, "y"}) {
+		t.Errorf("Wrong SINTER: %v", res)
+	}
+	if res, _ := s.SDIFF([]string{"a", "b", "missing"}); !slices.Equal(res, []any{1, "x"}) {
+		t.Errorf("Wrong SDIFF: %v", res)
+	}
+	if n, _ := s.SINTERSTORE("c", []string{"a", "b"}); n != 2 {
+		t.Errorf("Wrong SINTERSTORE size: %d", n)
+	}
+	if n, _ := s.SCARD("c"); n != 2 {
+		t.Errorf("SINTERSTORE did not store result: %d", n)
+	}
+	if n, _ := s.SINTERSTORE("c", []string{"a", "missing"}); n != 0 || s.TYPE("c") != kindNoStruct {
+		t.Errorf("Empty SINTERSTORE did not delete destination")
+	}
+
+	if res, _ := s.SRANDMEMBER("a", -6); len(res) != 6 {
+		t.Errorf("SRANDMEMBER with negative count returned %d members", len(res))
+	}
+	if res, _ := s.SRANDMEMBER("a", 10); len(res) != 4 {
+		t.Errorf("SRANDMEMBER returned %d members", len(res))
+	}
+	if res, _ := s.SRANDMEMBER("a", math.MaxInt); len(res) != 4 {
+		t.Errorf("SRANDMEMBER with huge count returned %d members", len(res))
+	}
+	for _, count := range []int{math.MinInt, -maxRandomRepeats - 1} {
+		if _, err := s.SRANDMEMBER("a", count); err == nil {
+			t.Errorf("SRANDMEMBER accepted count %d", count)
+		}
+	}
+
+	state := s.getState()
+	restored := newTestStorage()
+	restored.recoverFromCondition(state)
+	if members, _ := restored.SMEMBERS("b"); !slices.Equal(members, []any{2, "y", "z"}) {
+		t.Errorf("Set was not restored: %v", members)
+	}
+
+	if removed, _ := s.SREM("b", []any{"z", "missing"}); removed != 1 {
+		t.Errorf("Wrong SREM count: %d", removed)
+	}
+	popped, _ := s.SPOP("b", 5)
+	if len(popped) != 2 || s.TYPE("b") != kindNoStruct {
+		t.Errorf("SPOP did not empty the set: %v", popped)
+	}
+}
+
+func zsetMembers(members []ZMember) []string {
+	res := make([]string, 0, len(members))
+	for _, m := range members {
+		res = append(res, m.Member)
+	}
+	return res
+}
+
+func TestSortedSet(t *testing.T) {
+	s := newTestStorage()
+
+	added, _ := s.ZADD("board", []ZMember{
+		{Member: "alice", Score: 30},
+		{Member: "bob", Score: 10},
+		{Member: "carol", Score: 20},
+		{Member: "dave", Score: 20},
+	})
+	if added != 4 {
+		t.Errorf("Wrong ZADD count: %d", added)
+	}
+	if added, _ := s.ZADD("board", []ZMember{{Member: "bob", Score: 40}, {Member: "erin", Score: 5}}); added != 1 {
+		t.Errorf("ZADD counted updated member: %d", added)
+	}
+	if res, _ := s.ZRANGE("board", 0, -1, false); !slices.Equal(zsetMembers(res), []string{"erin", "carol", "dave", "alice", "bob"}) {
+		t.Errorf("Wrong ZRANGE: %v", res)
+	}
+	if res, _ := s.ZRANGE("board", 0, 1, true); !slices.Equal(zsetMembers(res), []string{"bob", "alice"}) {
+		t.Errorf("Wrong reversed ZRANGE: %v", res)
+	}
+	if rank, _ := s.ZRANK("board", "dave"); rank == nil || *rank != 2 {
+		t.Errorf("Wrong ZRANK: %v", rank)
+	}
+	if rank, _ := s.ZREVRANK("board", "bob"); rank == nil || *rank != 0 {
+		t.Errorf("Wrong ZREVRANK: %v", rank)
+	}
+	if rank, _ := s.ZRANK("board", "nobody"); rank != nil {
+		t.Errorf("ZRANK found missing member")
+	}
+
+	if score, _ := s.ZINCRBY("board", "erin", 21.5); score != 26.5 {
+		t.Errorf("Wrong ZINCRBY result: %v", score)
+	}
+	if score, _ := s.ZSCORE("board", "erin"); score == nil || *score != 26.5 {
+		t.Errorf("Wrong ZSCORE: %v", score)
+	}
+	if _, err := s.ZADD("board", []ZMember{{Member: "frank", Score: math.Inf(1)}}); err == nil {
+		t.Errorf("ZADD accepted infinite score")
+	}
+	if _, err := s.ZINCRBY("board", "erin", math.Inf(-1)); err == nil {
+		t.Errorf("ZINCRBY accepted infinite increment")
+	}
+	s.ZADD("board", []ZMember{{Member: "frank", Score: math.MaxFloat64}})
+	if _, err := s.ZINCRBY("board", "frank", math.MaxFloat64); err == nil {
+		t.Errorf("ZINCRBY overflowed to infinity")
+	}
+	if score, _ := s.ZSCORE("board", "frank"); score == nil || *score != math.MaxFloat64 {
+		t.Errorf("ZINCRBY changed score on overflow: %v", score)
+	}
+	s.ZREM("board", []string{"frank"})
+
+	if res, _ := s.ZRANGEBYSCORE("board", "20", "(30", false, 0, -1); !slices.Equal(zsetMembers(res), []string{"carol", "dave", "erin"}) {
+		t.Errorf("Wrong ZRANGEBYSCORE: %v", res)
+	}
+	if res, _ := s.ZRANGEBYSCORE("board", "(20", "+inf", true, 1, 2); !slices.Equal(zsetMembers(res), []string{"alice", "erin"}) {
+		t.Errorf("Wrong reversed ZRANGEBYSCORE with limit: %v", res)
+	}
+	if _, err := s.ZRANGEBYSCORE("board", "low", "10", false, 0, -1); err == nil {
+		t.Errorf("ZRANGEBYSCORE accepted invalid bound")
+	}
+
+	s.ZADD("names", []ZMember{{Member: "a"}, {Member: "b"}, {Member: "c"}, {Member: "d"}})
+	if res, _ := s.ZRANGEBYLEX("names", "(a", "[c", false, 0, -1); !slices.Equal(zsetMembers(res), []string{"b", "c"}) {
+		t.Errorf("Wrong ZRANGEBYLEX: %v", res)
+	}
+	if res, _ := s.ZRANGEBYLEX("names", "-", "+", true, 0, 3); !slices.Equal(zsetMembers(res), []string{"d", "c", "b"}) {
+		t.Errorf("Wrong reversed ZRANGEBYLEX: %v", res)
+	}
+
+	state := s.getState()
+	restored := newTestStorage()
+	restored.recoverFromCondition(state)
+	if res, _ := restored.ZRANGE("board", 0, -1, false); !slices.Equal(zsetMembers(res), []string{"carol", "dave", "erin", "alice", "bob"}) {
+		t.Errorf("Sorted set was not restored: %v", res)
+	}
+
+	if res, _ := s.ZPOPMIN("board", 2); !slices.Equal(zsetMembers(res), []string{"carol", "dave"}) {
+		t.Errorf("Wrong ZPOPMIN: %v", res)
+	}
+	if res, _ := s.ZPOPMAX("board", 1); !slices.Equal(zsetMembers(res), []string{"bob"}) {
+		t.Errorf("Wrong ZPOPMAX: %v", res)
+	}
+	if removed, _ := s.ZREM("board", []string{"erin", "alice", "nobody"}); removed != 2 || s.TYPE("board") != kindNoStruct {
+		t.Errorf("ZREM did not delete empty sorted set: %d", removed)
+	}
+	if _, err := s.SADD("names", []any{"x"}); err == nil {
+		t.Errorf("Set command accepted sorted set key")
+	}
+}
+
+func streamIDs(entries []StreamEntry) []string {
+	res := make([]string, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, e.ID)
+	}
+	return res
+}
+
+func TestStream(t *testing.T) {
+	s := newTestStorage()
+
+	for i := 1; i <= 4; i++ {
+		if _, err := s.XADD("events", fmt.Sprintf("1-%d", i), map[string]any{"n": i}, 0); err != nil {
+			t.Fatalf("XADD failed: %v", err)
+		}
+	}
+	if _, err := s.XADD("events", "1-2", map[string]any{"n": 0}, 0); err == nil {
+		t.Errorf("XADD accepted smaller ID")
+	}
+	if id, _ := s.XADD("events", "1-*", map[string]any{"n": 5}, 0); id != "1-5" {
+		t.Errorf("Wrong generated sequence: %s", id)
+	}
+	id, _ := s.XADD("events", "*", map[string]any{"n": 6}, 5)
+	if n, _ := s.XLEN("events"); n != 5 {
+		t.Errorf("MAXLEN did not trim stream: %d", n)
+	}
+
+	if res, _ := s.XRANGE("events", "-", "+", -1); !slices.Equal(streamIDs(res), []string{"1-2", "1-3", "1-4", "1-5", id}) {
+		t.Errorf("Wrong XRANGE: %v", res)
+	}
+	if res, _ := s.XRANGE("events", "(1-2", "1", 2); !slices.Equal(streamIDs(res), []string{"1-3", "1-4"}) || res[0].Fields["n"] != 3 {
+		t.Errorf("Wrong XRANGE with limit: %v", res)
+	}
+	if res, _ := s.XREVRANGE("events", "+", "-", 2); !slices.Equal(streamIDs(res), []string{id, "1-5"}) {
+		t.Errorf("Wrong XREVRANGE: %v", res)
+	}
+
+	if err := s.XGROUPCREATE("events", "workers", "1-3", false); err != nil {
+		t.Fatalf("XGROUPCREATE failed: %v", err)
+	}
+	if err := s.XGROUPCREATE("events", "workers", "0", false); err == nil {
+		t.Errorf("XGROUPCREATE created group twice")
+	}
+	if res, _ := s.XREADGROUP("events", "workers", "a", ">", 2); !slices.Equal(streamIDs(res), []string{"1-4", "1-5"}) {
+		t.Errorf("Wrong XREADGROUP: %v", res)
+	}
+	if res, _ := s.XREADGROUP("events", "workers", "b", ">", -1); !slices.Equal(streamIDs(res), []string{id}) {
+		t.Errorf("XREADGROUP delivered entry twice: %v", res)
+	}
+	if res, _ := s.XREADGROUP("events", "workers", "a", "0", -1); !slices.Equal(streamIDs(res), []string{"1-4", "1-5"}) {
+		t.Errorf("Wrong pending history: %v", res)
+	}
+	if acked, _ := s.XACK("events", "workers", []string{"1-4", "1-4", "9-9"}); acked != 1 {
+		t.Errorf("Wrong XACK count: %d", acked)
+	}
+
+	if res, _ := s.XCLAIM("events", "workers", "b", time.Hour, []string{"1-5"}); len(res) != 0 {
+		t.Errorf("XCLAIM ignored min idle time: %v", res)
+	}
+	if res, _ := s.XCLAIM("events", "workers", "b", 0, []string{"1-5"}); !slices.Equal(streamIDs(res), []string{"1-5"}) {
+		t.Errorf("Wrong XCLAIM: %v", res)
+	}
+	pending, _ := s.XPENDING("events", "workers")
+	if len(pending) != 2 || pending[0].Consumer != "b" || pending[0].Deliveries != 2 {
+		t.Errorf("Wrong XPENDING: %v", pending)
+	}
+
+	state := s.getState()
+	restored := newTestStorage()
+	restored.recoverFromCondition(state)
+	if res, _ := restored.XRANGE("events", "-", "+", -1); len(res) != 5 || res[0].Fields["n"] != 2 {
+		t.Errorf("Stream was not restored: %v", res)
+	}
+	if pending, _ := restored.XPENDING("events", "workers"); len(pending) != 2 {
+		t.Errorf("Pending list was not restored: %v", pending)
+	}
+	if _, err := restored.XADD("events", "1-1", map[string]any{"n": 1}, 0); err == nil {
+		t.Errorf("Last ID was not restored")
+	}
+
+	done := make(chan []StreamEntry)
+	go func() {
+		res, _ := s.BXREADGROUP(context.Background(), "events", "workers", "c", -1, 5*time.Second)
+		done <- res
+	}()
+	time.Sleep(50 * time.Millisecond)
+	s.XADD("events", "*", map[string]any{"n": 7}, 0)
+	select {
+	case res := <-done:
+		if len(res) != 1 || res[0].Fields["n"] != 7 {
+			t.Errorf("Wrong BXREADGROUP result: %v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("BXREADGROUP was not woken up by XADD")
+	}
+	if res, err := s.BXREADGROUP(context.Background(), "events", "workers", "c", -1, 50*time.Millisecond); len(res) != 0 || err != nil {
+		t.Errorf("BXREADGROUP did not time out: %v %v", res, err)
+	}
+}
+
+func TestBitmap(t *testing.T) {
+	s := newTestStorage()
+
+	for _, offset := range []int{1, 2, 9, 23} {
+		s.SETBIT("mon", offset, 1)
+	}
+	if old, _ := s.SETBIT("mon", 2, 0); old != 1 {
+		t.Errorf("Wrong previous bit: %d", old)
+	}
+	if bit, _ := s.GETBIT("mon", 9); bit != 1 {
+		t.Errorf("Wrong GETBIT")
+	}
+	if bit, _ := s.GETBIT("mon", 1000); bit != 0 {
+		t.Errorf("GETBIT out of range returned %d", bit)
+	}
+	if !slices.Equal(s.innerBitmap["mon"], []byte{0x40, 0x40, 0x01}) {
+		t.Errorf("Wrong bitmap bytes: %v", s.innerBitmap["mon"])
+	}
+
+	if n, _ := s.BITCOUNT("mon", 0, -1, false); n != 3 {
+		t.Errorf("Wrong BITCOUNT: %d", n)
+	}
+	if n, _ := s.BITCOUNT("mon", 1, 1, false); n != 1 {
+		t.Errorf("Wrong BITCOUNT by bytes: %d", n)
+	}
+	if n, _ := s.BITCOUNT("mon", 1, 9, true); n != 2 {
+		t.Errorf("Wrong BITCOUNT by bits: %d", n)
+	}
+
+	if pos, _ := s.BITPOS("mon", 1, 1, nil, false); pos != 9 {
+		t.Errorf("Wrong BITPOS: %d", pos)
+	}
+	if pos, _ := s.BITPOS("mon", 1, 10, nil, true); pos != 23 {
+		t.Errorf("Wrong BITPOS by bits: %d", pos)
+	}
+	s.SETBIT("zeros", 7, 0)
+	s.BITOP("NOT", "ones", []string{"zeros"})
+	if pos, _ := s.BITPOS("ones", 0, 0, nil, false); pos != 8 {
+		t.Errorf("BITPOS did not return offset after full bitmap: %d", pos)
+	}
+	end := -1
+	if pos, _ := s.BITPOS("ones", 0, 0, &end, false); pos != -1 {
+		t.Errorf("BITPOS with end found clear bit: %d", pos)
+	}
+
+	s.SETBIT("tue", 1, 1)
+	s.SETBIT("tue", 30, 1)
+	if n, _ := s.BITOP("AND", "both", []string{"mon", "tue"}); n != 4 {
+		t.Errorf("Wrong BITOP size: %d", n)
+	}
+	if !slices.Equal(s.innerBitmap["both"], []byte{0x40, 0, 0, 0}) {
+		t.Errorf("Wrong BITOP AND: %v", s.innerBitmap["both"])
+	}
+	s.BITOP("XOR", "either", []string{"mon", "tue"})
+	if n, _ := s.BITCOUNT("either", 0, -1, false); n != 3 {
+		t.Errorf("Wrong BITOP XOR count: %d", n)
+	}
+	if _, err := s.BITOP("NOT", "x", []string{"mon", "tue"}); err == nil {
+		t.Errorf("BITOP NOT accepted two keys")
+	}
+
+	state := s.getState()
+	restored := newTestStorage()
+	restored.recoverFromCondition(state)
+	if n, _ := restored.BITCOUNT("mon", 0, -1, false); n != 3 {
+		t.Errorf("Bitmap was not restored: %d", n)
+	}
+	if _, err := s.SETBIT("mon", -1, 1); err == nil {
+		t.Errorf("SETBIT accepted negative offset")
+	}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	s := newTestStorage()
+
+	if changed, _ := s.PFADD("visitors", []any{"a", "b", "c"}); !changed {
+		t.Errorf("PFADD did not report change")
+	}
+	if changed, _ := s.PFADD("visitors", []any{"a", "b"}); changed {
+		t.Errorf("PFADD reported change for known elements")
+	}
+	if n, _ := s.PFCOUNT([]string{"visitors"}); n != 3 {
+		t.Errorf("Wrong small PFCOUNT: %d", n)
+	}
+	if len(s.innerHLL["visitors"]) != hllSize {
+		t.Errorf("Wrong register array size: %d", len(s.innerHLL["visitors"]))
+	}
+
+	elems := make([]any, 0, 50000)
+	for i := 0; i < 50000; i++ {
+		elems = append(elems, "user"+strconv.Itoa(i))
+	}
+	s.PFADD("big", elems[:30000])
+	s.PFADD("other", elems[20000:])
+	checkEstimate := func(name string, got int, want int) {
+		if math.Abs(float64(got-want)) > 0.03*float64(want) {
+			t.Errorf("%s estimate %d is too far from %d", name, got, want)
+		}
+	}
+	n, _ := s.PFCOUNT([]string{"big"})
+	checkEstimate("PFCOUNT", n, 30000)
+	n, _ = s.PFCOUNT([]string{"big", "other", "missing"})
+	checkEstimate("Union PFCOUNT", n, 50000)
+
+	if err := s.PFMERGE("all", []string{"big", "other"}); err != nil {
+		t.Fatalf("PFMERGE failed: %v", err)
+	}
+	n, _ = s.PFCOUNT([]string{"all"})
+	checkEstimate("PFMERGE", n, 50000)
+	s.Expire("all", 100)
+	s.PFMERGE("all", []string{"visitors"})
+	if s.innerExpire["all"] == 0 {
+		t.Errorf("PFMERGE reset time to live of dst")
+	}
+
+	state := s.getState()
+	if len(state.InnerHLL["visitors"].Sparse) != 9 || state.InnerHLL["big"].Dense == nil {
+		t.Errorf("Wrong HyperLogLog encoding in snapshot")
+	}
+	restored := newTestStorage()
+	restored.recoverFromCondition(state)
+	for _, key := range []string{"visitors", "all"} {
+		want, _ := s.PFCOUNT([]string{key})
+		if got, _ := restored.PFCOUNT([]string{key}); got != want {
+			t.Errorf("HyperLogLog %s was not restored: %d != %d", key, got, want)
+		}
+	}
+
+	s.SET("scalar", 1, 0)
+	if _, err := s.PFADD("scalar", []any{"a"}); err == nil {
+		t.Errorf("PFADD accepted scalar key")
+	}
+}
+
+func TestFilters(t *testing.T) {
+	s := newTestStorage()
+
+	if err := s.BFRESERVE("seen", 1000, 0.01); err != nil {
+		t.Fatalf("BFRESERVE failed: %v", err)
+	}
+	if err := s.BFRESERVE("seen", 1000, 0.01); err == nil {
+		t.Errorf("BFRESERVE replaced existing filter")
+	}
+	if err := s.BFRESERVE("bad", 1000, 1.5); err == nil {
+		t.Errorf("BFRESERVE accepted wrong error rate")
+	}
+	if err := s.BFRESERVE("bad", math.MaxInt, 0.01); err == nil {
+		t.Errorf("BFRESERVE accepted huge capacity")
+	}
+	if err := s.BFRESERVE("bad", 1<<30, 1e-300); err == nil {
+		t.Errorf("BFRESERVE accepted filter over 512MB")
+	}
+	if err := s.CFRESERVE("bad", 1<<30, 0.01); err == nil {
+		t.Errorf("CFRESERVE accepted filter over 512MB")
+	}
+	if s.getLiveStruct("bad") != kindNoStruct {
+		t.Errorf("Rejected reserve created a key")
+	}
+
+	items := make([]any, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		items = append(items, "id"+strconv.Itoa(i))
+	}
+	s.BFMADD("seen", items)
+	if added, _ := s.BFADD("seen", "id7"); added {
+		t.Errorf("BFADD reported known item as new")
+	}
+	falsePositives := 0
+	for i := 0; i < 1000; i++ {
+		if ok, _ := s.BFEXISTS("seen", items[i]); !ok {
+			t.Fatalf("Bloom filter lost %v", items[i])
+		}
+		if ok, _ := s.BFEXISTS("seen", "other"+strconv.Itoa(i)); ok {
+			falsePositives++
+		}
+	}
+	if falsePositives > 30 {
+		t.Errorf("Too many Bloom filter false positives: %d", falsePositives)
+	}
+
+	s.CFRESERVE("jobs", 100, 0.001)
+	for i := 0; i < 100; i++ {
+		if err := s.CFADD("jobs", i); err != nil {
+			t.Fatalf("CFADD failed at %d: %v", i, err)
+		}
+	}
+	if added, _ := s.CFADDNX("jobs", 5); added {
+		t.Errorf("CFADDNX added existing item")
+	}
+	if ok, _ := s.CFEXISTS("jobs", 42); !ok {
+		t.Errorf("Cuckoo filter lost item")
+	}
+	if deleted, _ := s.CFDEL("jobs", 42); !deleted {
+		t.Errorf("CFDEL did not find item")
+	}
+	if ok, _ := s.CFEXISTS("jobs", 42); ok {
+		t.Errorf("Cuckoo filter kept deleted item")
+	}
+
+	s.CFRESERVE("tiny", 8, 0.01)
+	var full error
+	added := 0
+	for ; added < 100 && full == nil; added++ {
+		full = s.CFADD("tiny", added)
+	}
+	if full == nil {
+		t.Errorf("Overfilled cuckoo filter accepted all items")
+	}
+	for i := 0; i < added-1; i++ {
+		if ok, _ := s.CFEXISTS("tiny", i); !ok {
+			t.Errorf("Failed CFADD evicted item %d", i)
+		}
+	}
+
+	state := s.getState()
+	restored := newTestStorage()
+	restored.recoverFromCondition(state)
+	if ok, _ := restored.BFEXISTS("seen", "id999"); !ok {
+		t.Errorf("Bloom filter was not restored")
+	}
+	if ok, _ := restored.CFEXISTS("jobs", 99); !ok {
+		t.Errorf("Cuckoo filter was not restored")
+	}
+	if err := s.CFADD("seen", 1); err == nil {
+		t.Errorf("CFADD accepted Bloom filter key")
+	}
+}
+
+func TestSketches(t *testing.T) {
+	s := newTestStorage()
+
+	if err := s.CMSINITBYPROB("hits", 0.001, 0.01); err != nil {
+		t.Fatalf("CMSINITBYPROB failed: %v", err)
+	}
+	if err := s.CMSINITBYDIM("hits", 10, 2); err == nil {
+		t.Errorf("CMSINITBYDIM replaced existing sketch")
+	}
+	if _, err := s.CMSINCRBY("missing", []ItemCount{{Item: "a", Count: 1}}); err == nil {
+		t.Errorf("CMSINCRBY created sketch")
+	}
+
+	want := make(map[string]int)
+	for i := 0; i < 500; i++ {
+		url := "/page/" + strconv.Itoa(i%50)
+		want[url] += i%7 + 1
+		s.CMSINCRBY("hits", []ItemCount{{Item: url, Count: i%7 + 1}})
+	}
+	for url, count := range want {
+		got, _ := s.CMSQUERY("hits", []any{url})
+		if got[0] < count || got[0] > count+5 {
+			t.Errorf("Wrong estimate for %s: %d, want %d", url, got[0], count)
+		}
+	}
+
+	s.CMSINITBYDIM("a", 100, 4)
+	s.CMSINITBYDIM("b", 100, 4)
+	s.CMSINITBYDIM("sum", 100, 4)
+	s.CMSINCRBY("a", []ItemCount{{Item: "x", Count: 3}})
+	s.CMSINCRBY("b", []ItemCount{{Item: "x", Count: 5}})
+	if err := s.CMSMERGE("sum", []string{"a", "b"}, []int{2, 1}); err != nil {
+		t.Fatalf("CMSMERGE failed: %v", err)
+	}
+	if got, _ := s.CMSQUERY("sum", []any{"x"}); got[0] != 11 {
+		t.Errorf("Wrong merged estimate: %d", got[0])
+	}
+	if err := s.CMSMERGE("sum", []string{"hits"}, nil); err == nil {
+		t.Errorf("CMSMERGE accepted sketches of different dimensions")
+	}
+	if err := s.CMSMERGE("sum", []string{"a", "b"}, []int{2, -1}); err == nil {
+		t.Errorf("CMSMERGE accepted negative weight")
+	}
+	if err := s.CMSINITBYDIM("huge", 1<<20, 1<<10); err == nil {
+		t.Errorf("CMSINITBYDIM accepted sketch over 512MB")
+	}
+	if err := s.CMSINITBYPROB("huge", 1e-300, 0.01); err == nil {
+		t.Errorf("CMSINITBYPROB accepted sketch over 512MB")
+	}
+	if err := s.TOPKRESERVE("huge", 3, math.MaxInt, 2, 0); err == nil {
+		t.Errorf("TOPKRESERVE accepted huge width")
+	}
+	if err := s.TOPKRESERVE("huge", maxTopKItems+1, 0, 0, 0); err == nil {
+		t.Errorf("TOPKRESERVE accepted huge k")
+	}
+	if err := s.TOPKRESERVE("wide", maxTopKItems, 0, 0, 0); err != nil {
+		t.Fatalf("TOPKRESERVE failed: %v", err)
+	}
+	for i := 0; i < 2000; i++ {
+		s.TOPKADD("wide", []any{i % 300})
+	}
+	tk := s.innerTopK["wide"]
+	for i, ic := range tk.heap {
+		if i > 0 && tk.heap[(i-1)/2].Count > ic.Count {
+			t.Fatalf("Top-K heap is broken at %d", i)
+		}
+		if tk.index[ic.Item.(string)] != i {
+			t.Fatalf("Top-K index is broken for %v", ic.Item)
+		}
+	}
+	if len(tk.heap) != 300 || len(tk.index) != 300 {
+		t.Errorf("Wrong Top-K size: %d", len(tk.heap))
+	}
+	s.CMSINITBYDIM("big", 10, 2)
+	s.CMSINCRBY("big", []ItemCount{{Item: "x", Count: math.MaxInt}})
+	if got, _ := s.CMSINCRBY("big", []ItemCount{{Item: "x", Count: 1}}); got[0] != math.MaxInt {
+		t.Errorf("CMSINCRBY overflowed: %d", got[0])
+	}
+	s.CMSINITBYDIM("bigsum", 10, 2)
+	if err := s.CMSMERGE("bigsum", []string{"big", "big"}, []int{3, 1}); err != nil {
+		t.Fatalf("CMSMERGE failed: %v", err)
+	}
+	if got, _ := s.CMSQUERY("bigsum", []any{"x"}); got[0] != math.MaxInt {
+		t.Errorf("CMSMERGE overflowed: %d", got[0])
+	}
+
+	if err := s.TOPKRESERVE("endpoints", 3, 0, 0, 0); err != nil {
+		t.Fatalf("TOPKRESERVE failed: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		item := "rare" + strconv.Itoa(i)
+		switch {
+		case i%3 == 0:
+			item = "/login"
+		case i%5 == 0:
+			item = "/search"
+		case i%7 == 0:
+			item = "/cart"
+		}
+		s.TOPKADD("endpoints", []any{item})
+	}
+	top, _ := s.TOPKLIST("endpoints")
+	if len(top) != 3 || top[0].Item != "/login" || top[1].Item != "/search" || top[2].Item != "/cart" {
+		t.Errorf("Wrong top list: %v", top)
+	}
+
+	state := s.getState()
+	restored := newTestStorage()
+	restored.recoverFromCondition(state)
+	if got, _ := restored.CMSQUERY("sum", []any{"x"}); got[0] != 11 {
+		t.Errorf("Count-min sketch was not restored")
+	}
+	if got, _ := restored.TOPKLIST("endpoints"); !slices.Equal(got, top) {
+		t.Errorf("Top list was not restored: %v", got)
+	}
+	restored.TOPKADD("endpoints", []any{"/login"})
+	if got, _ := restored.TOPKLIST("endpoints"); got[0].Count != top[0].Count+1 {
+		t.Errorf("Top-K counters were not restored")
+	}
+
+	s.SET("scalar", 1, 0)
+	if _, err := s.CMSQUERY("scalar", []any{"a"}); err == nil {
+		t.Errorf("CMSQUERY accepted scalar key")
+	}
+}
+
+func TestGeo(t *testing.T) {
+	s := newTestStorage()
+
+	couriers := []GeoMember{
+		{Member: "kremlin", GeoPoint: GeoPoint{Longitude: 37.6176, Latitude: 55.7520}},
+		{Member: "gum", GeoPoint: GeoPoint{Longitude: 37.6215, Latitude: 55.7547}},
+		{Member: "arbat", GeoPoint: GeoPoint{Longitude: 37.5917, Latitude: 55.7494}},
+		{Member: "vdnh", GeoPoint: GeoPoint{Longitude: 37.6385, Latitude: 55.8263}},
+		{Member: "palermo", GeoPoint: GeoPoint{Longitude: 13.361389, Latitude: 38.115556}},
+		{Member: "fiji", GeoPoint: GeoPoint{Longitude: 179.99, Latitude: -17.7}},
+		{Member: "samoa", GeoPoint: GeoPoint{Longitude: -179.99, Latitude: -17.7}},
+	}
+	if n, err := s.GEOADD("couriers", couriers); n != len(couriers) || err != nil {
+		t.Fatalf("GEOADD failed: %d, %v", n, err)
+	}
+	if _, err := s.GEOADD("couriers", []GeoMember{{Member: "pole", GeoPoint: GeoPoint{Latitude: 89}}}); err == nil {
+		t.Errorf("GEOADD accepted invalid latitude")
+	}
+
+	pos, _ := s.GEOPOS("couriers", []string{"palermo", "missing"})
+	if pos[0] == nil || math.Abs(pos[0].Longitude-13.361389) > 1e-5 || math.Abs(pos[0].Latitude-38.115556) > 1e-5 {
+		t.Errorf("Wrong GEOPOS: %v", pos[0])
+	}
+	if pos[1] != nil {
+		t.Errorf("GEOPOS found missing member")
+	}
+
+	dist, _ := s.GEODIST("couriers", "kremlin", "vdnh", "km")
+	if dist == nil || math.Abs(*dist-8.3) > 0.1 {
+		t.Errorf("Wrong GEODIST: %v", dist)
+	}
+	if dist, _ := s.GEODIST("couriers", "kremlin", "missing", "m"); dist != nil {
+		t.Errorf("GEODIST found missing member")
+	}
+	if _, err := s.GEODIST("couriers", "kremlin", "gum", "parsec"); err == nil {
+		t.Errorf("GEODIST accepted unknown unit")
+	}
+
+	members := func(res []GeoResult) []string {
+		names := make([]string, 0, len(res))
+		for _, r := range res {
+			names = append(names, r.Member)
+		}
+		return names
+	}
+	res, err := s.GEOSEARCH("couriers", GeoQuery{FromMember: "kremlin", Radius: 2, Unit: "km"})
+	if err != nil || !slices.Equal(members(res), []string{"kremlin", "gum", "arbat"}) {
+		t.Errorf("Wrong GEOSEARCH by radius: %v, %v", res, err)
+	}
+	res, _ = s.GEOSEARCH("couriers", GeoQuery{
+		FromPoint: &GeoPoint{Longitude: 37.6176, Latitude: 55.7520},
+		Width:     1000,
+		Height:    1000,
+		Desc:      true,
+	})
+	if !slices.Equal(members(res), []string{"gum", "kremlin"}) {
+		t.Errorf("Wrong GEOSEARCH by box: %v", res)
+	}
+	res, _ = s.GEOSEARCH("couriers", GeoQuery{FromMember: "fiji", Radius: 50, Unit: "km", Count: 5})
+	if !slices.Equal(members(res), []string{"fiji", "samoa"}) {
+		t.Errorf("GEOSEARCH did not cross antimeridian: %v", res)
+	}
+	res, _ = s.GEOSEARCH("couriers", GeoQuery{FromMember: "palermo", Radius: 20000, Unit: "km", Count: 2})
+	if !slices.Equal(members(res), []string{"palermo", "arbat"}) {
+		t.Errorf("Wrong GEOSEARCH with count: %v", res)
+	}
+	if _, err := s.GEOSEARCH("couriers", GeoQuery{FromMember: "kremlin", Radius: 1, Width: 1, Height: 1}); err == nil {
+		t.Errorf("GEOSEARCH accepted both radius and box")
+	}
+	if _, err := s.GEOSEARCH("couriers", GeoQuery{FromMember: "missing", Radius: 1}); err == nil {
+		t.Errorf("GEOSEARCH accepted missing center member")
+	}
+
+	restored := newTestStorage()
+	restored.recoverFromCondition(s.getState())
+	if dist, _ := restored.GEODIST("couriers", "fiji", "samoa", "m"); dist == nil {
+		t.Errorf("Geo index was not restored")
+	}
+}
+
+func TestTimeSeries(t *testing.T) {
+	s := newTestStorage()
+
+	if err := s.TSCREATE("cpu", 10000); err != nil {
+		t.Fatalf("TSCREATE failed: %v", err)
+	}
+	s.TSCREATE("cpu:avg", 0)
+	s.TSCREATE("cpu:max", 0)
+	if err := s.TSCREATERULE("cpu", "cpu:avg", "avg", 1000); err != nil {
+		t.Fatalf("TSCREATERULE failed: %v", err)
+	}
+	s.TSCREATERULE("cpu", "cpu:max", "MAX", 1000)
+	if err := s.TSCREATERULE("cpu:avg", "cpu:max", "sum", 1000); err == nil {
+		t.Errorf("TSCREATERULE chained compactions")
+	}
+
+	for i := int64(0); i < 50; i++ {
+		if _, err := s.TSADD("cpu", 500+i*100, float64(i%10)); err != nil {
+			t.Fatalf("TSADD failed: %v", err)
+		}
+	}
+	s.TSADD("cpu", 1500, 20)
+	s.TSADD("cpu", 1550, -5)
+
+	samples, _ := s.TSRANGE("cpu", 1000, 1200, "", 0)
+	want := []Sample{{1000, 5}, {1100, 6}, {1200, 7}}
+	if !slices.Equal(samples, want) {
+		t.Errorf("Wrong raw TSRANGE: %v", samples)
+	}
+	samples, _ = s.TSRANGE("cpu", 0, 2999, "sum", 1000)
+	want = []Sample{{0, 10}, {1000, 45 + 20 - 5}, {2000, 45}}
+	if !slices.Equal(samples, want) {
+		t.Errorf("Wrong aggregated TSRANGE: %v", samples)
+	}
+	if _, err := s.TSRANGE("cpu", 0, 100, "median", 1000); err == nil {
+		t.Errorf("TSRANGE accepted unknown aggregation")
+	}
+
+	for _, rule := range []struct {
+		key         string
+		aggregation string
+	}{{"cpu:avg", "avg"}, {"cpu:max", "max"}} {
+		got, _ := s.TSRANGE(rule.key, 0, math.MaxInt64, "", 0)
+		want, _ := s.TSRANGE("cpu", 0, math.MaxInt64, rule.aggregation, 1000)
+		if !slices.Equal(got, want) {
+			t.Errorf("Wrong compaction to %s: %v != %v", rule.key, got, want)
+		}
+	}
+
+	s.TSADD("cpu", 15000, 1)
+	if _, err := s.TSADD("cpu", 4999, 1); err == nil {
+		t.Errorf("TSADD accepted sample older than retention")
+	}
+	if samples, _ := s.TSRANGE("cpu", 0, 4999, "", 0); len(samples) != 0 {
+		t.Errorf("TSRANGE returned samples older than retention")
+	}
+	s.garbageCollector()
+	if n := s.innerTimeSeries["cpu"].samples.Len(); n != 6 {
+		t.Errorf("Retention was not applied: %d samples left", n)
+	}
+
+	restored := newTestStorage()
+	restored.recoverFromCondition(s.getState())
+	restored.TSADD("cpu", 15001, 3)
+	if got, _ := restored.TSRANGE("cpu:avg", 15000, 15000, "", 0); !slices.Equal(got, []Sample{{15000, 2}}) {
+		t.Errorf("Compaction rules were not restored: %v", got)
+	}
+
+	if err := restored.TSDELETERULE("cpu", "cpu:max"); err != nil {
+		t.Errorf("TSDELETERULE failed: %v", err)
+	}
+	restored.DEL([]string{"cpu:avg"})
+	if len(restored.innerTimeSeries["cpu"].rules) != 0 {
+		t.Errorf("Rule to deleted time series was kept")
+	}
+
+	if _, err := s.TSADD("big", 0, math.Inf(1)); err == nil {
+		t.Errorf("TSADD accepted infinite value")
+	}
+	s.TSADD("big", 0, math.MaxFloat64)
+	s.TSADD("big", 1, math.MaxFloat64)
+	if _, err := s.TSRANGE("big", 0, 1, "avg", 1000); err == nil {
+		t.Errorf("TSRANGE returned infinite aggregate")
+	}
+	s.TSCREATE("big:sum", 0)
+	s.TSCREATERULE("big", "big:sum", "sum", 1000)
+	s.TSADD("big", 1, 1)
+	if _, err := s.TSADD("big", 1, math.MaxFloat64); err == nil {
+		t.Errorf("TSADD compacted infinite aggregate")
+	}
+	if got, _ := s.TSRANGE("big", 0, 1, "", 0); !slices.Equal(got, []Sample{{0, math.MaxFloat64}, {1, 1}}) {
+		t.Errorf("Rejected TSADD changed samples: %v", got)
+	}
+	if got, _ := s.TSRANGE("big:sum", 0, 1, "", 0); len(got) != 1 || math.IsInf(got[0].Value, 0) {
+		t.Errorf("Rejected TSADD changed compaction: %v", got)
+	}
+
+	s.SET("scalar", 1, 0)
+	if _, err := s.TSADD("scalar", 1, 1); err == nil {
+		t.Errorf("TSADD accepted scalar key")
+	}
+}
+
+func TestStateIsSnapshot(t *testing.T) {
+	s := newTestStorage()
+	s.SET("scalar", 1, 0)
+	s.HSET("hash", map[string]any{"a": 1, "c": 3})
+	s.HEXPIRE("hash", []string{"a"}, 100)
+
+	state := s.getState()
+	s.SET("other", 2, 0)
+	s.HSET("hash", map[string]any{"b": 2})
+	s.Expire("scalar", 100)
+	s.HEXPIRE("hash", []string{"c"}, 100)
+
+	if len(state.InnerScalar) != 1 || len(state.InnerMap["hash"]) != 2 || state.InnerExpire["scalar"] != 0 {
+		t.Errorf("State shares maps with storage: %v", state)
+	}
+	if len(state.InnerFieldExpire["hash"]) != 1 {
+		t.Errorf("State shares maps with storage: %v", state)
+	}
+}
@@ -0,0 +1,658 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type streamID struct {
+	ms  uint64
+	seq uint64
+}
+
+func (id streamID) String() string {
+	return fmt.Sprintf("%d-%d", id.ms, id.seq)
+}
+
+func lessStreamID(a, b streamID) bool {
+	if a.ms != b.ms {
+		return a.ms < b.ms
+	}
+	return a.seq < b.seq
+}
+
+// parseStreamID parses IDs like "1700000000000-1". If the sequence part
+// is omitted, it is set to defaultSeq.
+func parseStreamID(s string, defaultSeq uint64) (streamID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return streamID{}, errors.New("ValueError: invalid stream ID")
+	}
+	seq := defaultSeq
+	if hasSeq {
+		seq, err = strconv.ParseUint(seqPart, 10, 64)
+		if err != nil {
+			return streamID{}, errors.New("ValueError: invalid stream ID")
+		}
+	}
+	return streamID{ms: ms, seq: seq}, nil
+}
+
+// parseRangeID parses XRANGE limits: IDs, IDs prefixed with "(" to
+// exclude them, and "-" and "+" for the smallest and the greatest ID.
+func parseRangeID(s string, isEnd bool) (streamID, bool, error) {
+	excl := strings.HasPrefix(s, "(")
+	s = strings.TrimPrefix(s, "(")
+	switch s {
+	case "-":
+		return streamID{}, excl, nil
+	case "+":
+		return streamID{ms: math.MaxUint64, seq: math.MaxUint64}, excl, nil
+	}
+
+	var defaultSeq uint64
+	if isEnd {
+		defaultSeq = math.MaxUint64
+	}
+	id, err := parseStreamID(s, defaultSeq)
+	return id, excl, err
+}
+
+type streamEntry struct {
+	id     streamID
+	fields map[string]value
+}
+
+type StreamEntry struct {
+	ID     string         `json:"id"`
+	Fields map[string]any `json:"fields"`
+}
+
+func (e streamEntry) export() StreamEntry {
+	fields := make(map[string]any, len(e.fields))
+	for field, val := range e.fields {
+		fields[field] = val.Val
+	}
+	return StreamEntry{
+		ID:     e.id.String(),
+		Fields: fields,
+	}
+}
+
+type pendingEntry struct {
+	consumer    string
+	deliveredAt int64
+	deliveries  int
+}
+
+type PendingEntry struct {
+	ID         string `json:"id"`
+	Consumer   string `json:"consumer"`
+	Idle       int64  `json:"idle"`
+	Deliveries int    `json:"deliveries"`
+}
+
+// consumerGroup keeps the last ID delivered to the group and entries
+// that were delivered to its consumers but not acknowledged yet.
+type consumerGroup struct {
+	lastDelivered streamID
+	pending       map[streamID]*pendingEntry
+}
+
+type stream struct {
+	entries *orderedTreap[streamEntry]
+	lastID  streamID
+	groups  map[string]*consumerGroup
+}
+
+func newStream() *stream {
+	return &stream{
+		entries: newOrderedTreap(func(a, b streamEntry) bool {
+			return lessStreamID(a.id, b.id)
+		}),
+		groups: make(map[string]*consumerGroup),
+	}
+}
+
+// nextID returns ID for a new entry. id is either "*" for a generated ID,
+// "ms-*" for a generated sequence number or an explicit ID.
+func (st *stream) nextID(id string) (streamID, error) {
+	var res streamID
+	switch {
+	case id == "" || id == "*":
+		now := uint64(time.Now().UnixMilli())
+		if now > st.lastID.ms {
+			res = streamID{ms: now}
+		} else {
+			res = streamID{ms: st.lastID.ms, seq: st.lastID.seq + 1}
+		}
+	case strings.HasSuffix(id, "-*"):
+		ms, err := strconv.ParseUint(strings.TrimSuffix(id, "-*"), 10, 64)
+		if err != nil {
+			return res, errors.New("ValueError: invalid stream ID")
+		}
+		res = streamID{ms: ms}
+		if ms == st.lastID.ms {
+			res.seq = st.lastID.seq + 1
+		}
+	default:
+		var err error
+		res, err = parseStreamID(id, 0)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	if !lessStreamID(st.lastID, res) {
+		return res, errors.New("ValueError: the ID is equal or smaller than the stream top item")
+	}
+	return res, nil
+}
+
+func (st *stream) trim(maxLen int) {
+	if extra := st.entries.Len() - maxLen; extra > 0 {
+		for _, e := range st.entries.Slice(0, extra-1) {
+			st.entries.Delete(e)
+		}
+	}
+}
+
+func (r *Storage) getStream(key string) (*stream, error) {
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindStream && struct_kind != kindNoStruct {
+		return nil, errors.New("KeyError: this key already exists and has different type")
+	}
+	return r.innerStream[key], nil
+}
+
+func (r *Storage) getGroup(key string, group string) (*stream, *consumerGroup, error) {
+	st, err := r.getStream(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if st == nil || st.groups[group] == nil {
+		return nil, nil, errors.New("KeyError: no such key or consumer group")
+	}
+	return st, st.groups[group], nil
+}
+
+func (r *Storage) setStream(key string, st *stream) {
+	r.innerStream[key] = st
+	r.setKey(key, kindStream)
+	r.innerExpire[key] = 0
+}
+
+// XADD appends an entry with fields to the stream by key and returns its ID.
+// If maxLen is positive, the oldest entries are trimmed to keep at most
+// maxLen of them.
+func (r *Storage) XADD(key string, id string, fields map[string]any, maxLen int) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(fields) == 0 || maxLen < 0 {
+		return "", errors.New("WrongArgs")
+	}
+
+	st, err := r.getStream(key)
+	if err != nil {
+		return "", err
+	}
+	vals := make(map[string]value, len(fields))
+	for field, val := range fields {
+		vals[field], err = newValue(val)
+		if err != nil {
+			r.logger.Error(err.Error())
+			return "", err
+		}
+	}
+
+	if st == nil {
+		st = newStream()
+	}
+	newID, err := st.nextID(id)
+	if err != nil {
+		return "", err
+	}
+	if r.innerStream[key] == nil {
+		r.setStream(key, st)
+	}
+
+	st.entries.Insert(streamEntry{id: newID, fields: vals})
+	st.lastID = newID
+	if maxLen > 0 {
+		st.trim(maxLen)
+	}
+	r.notifyKey(key)
+	return newID.String(), nil
+}
+
+func (r *Storage) XLEN(key string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	st, err := r.getStream(key)
+	if err != nil || st == nil {
+		return 0, err
+	}
+	return st.entries.Len(), nil
+}
+
+// XRANGE returns entries with IDs from start to end inclusive. "-" and "+"
+// stand for the smallest and the greatest ID, a "(" prefix excludes the ID.
+// A negative count means no limit.
+func (r *Storage) XRANGE(key string, start string, end string, count int) ([]StreamEntry, error) {
+	return r.xrange(key, start, end, count, false)
+}
+
+// XREVRANGE is the same as XRANGE, but returns entries in reverse order
+// starting from end.
+func (r *Storage) XREVRANGE(key string, end string, start string, count int) ([]StreamEntry, error) {
+	return r.xrange(key, start, end, count, true)
+}
+
+func (r *Storage) xrange(key string, start string, end string, count int, rev bool) ([]StreamEntry, error) {
+	startID, startExcl, err := parseRangeID(start, false)
+	if err != nil {
+		return nil, err
+	}
+	endID, endExcl, err := parseRangeID(end, true)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	st, err := r.getStream(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]StreamEntry, 0)
+	if st == nil {
+		return res, nil
+	}
+
+	lo := st.entries.CountWhile(func(e streamEntry) bool {
+		return lessStreamID(e.id, startID) || (startExcl && e.id == startID)
+	})
+	hi := st.entries.CountWhile(func(e streamEntry) bool {
+		return lessStreamID(e.id, endID) || (!endExcl && e.id == endID)
+	})
+	if count >= 0 && hi-lo > count {
+		if rev {
+			lo = hi - count
+		} else {
+			hi = lo + count
+		}
+	}
+
+	entries := st.entries.Slice(lo, hi-1)
+	if rev {
+		slices.Reverse(entries)
+	}
+	for _, e := range entries {
+		res = append(res, e.export())
+	}
+	return res, nil
+}
+
+// XGROUPCREATE creates a consumer group that starts reading after id.
+// "$" stands for the last entry of the stream. If mkStream is set,
+// a missing stream is created empty.
+func (r *Storage) XGROUPCREATE(key string, group string, id string, mkStream bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	st, err := r.getStream(key)
+	if err != nil {
+		return err
+	}
+	if st == nil && !mkStream {
+		return errors.New("KeyError")
+	}
+
+	var lastDelivered streamID
+	if id != "$" {
+		lastDelivered, err = parseStreamID(id, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	if st == nil {
+		st = newStream()
+		r.setStream(key, st)
+	}
+	if _, ok := st.groups[group]; ok {
+		return errors.New("KeyError: consumer group already exists")
+	}
+	if id == "$" {
+		lastDelivered = st.lastID
+	}
+	st.groups[group] = &consumerGroup{
+		lastDelivered: lastDelivered,
+		pending:       make(map[streamID]*pendingEntry),
+	}
+	return nil
+}
+
+// XREADGROUP reads entries on behalf of consumer in group. With id ">"
+// it delivers entries that were never delivered to the group and adds them
+// to the pending list. Otherwise it returns entries pending for consumer
+// with IDs greater than id. A negative count means no limit.
+func (r *Storage) XREADGROUP(key string, group string, consumer string, id string, count int) ([]StreamEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.xreadgroup(key, group, consumer, id, count)
+}
+
+// BXREADGROUP is the same as XREADGROUP with id ">", but if there are
+// no new entries, it blocks until one is added, timeout expires or ctx
+// is done. Zero timeout blocks without a limit.
+func (r *Storage) BXREADGROUP(ctx context.Context, key string, group string, consumer string, count int, timeout time.Duration) ([]StreamEntry, error) {
+	if timeout < 0 {
+		return nil, errors.New("WrongArgs")
+	}
+
+	var res []StreamEntry
+	err := r.blockOn(ctx, []string{key}, timeout, func() (bool, error) {
+		var err error
+		res, err = r.xreadgroup(key, group, consumer, ">", count)
+		return len(res) > 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Storage) xreadgroup(key string, group string, consumer string, id string, count int) ([]StreamEntry, error) {
+	st, grp, err := r.getGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]StreamEntry, 0)
+	now := time.Now().UnixMilli()
+
+	if id == ">" {
+		lo := st.entries.CountWhile(func(e streamEntry) bool {
+			return !lessStreamID(grp.lastDelivered, e.id)
+		})
+		hi := st.entries.Len()
+		if count >= 0 {
+			hi = min(hi, lo+count)
+		}
+		for _, e := range st.entries.Slice(lo, hi-1) {
+			grp.pending[e.id] = &pendingEntry{
+				consumer:    consumer,
+				deliveredAt: now,
+				deliveries:  1,
+			}
+			grp.lastDelivered = e.id
+			res = append(res, e.export())
+		}
+		return res, nil
+	}
+
+	after, err := parseStreamID(id, 0)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]streamID, 0)
+	for _, pid := range grp.sortedPending() {
+		if grp.pending[pid].consumer == consumer && lessStreamID(after, pid) {
+			ids = append(ids, pid)
+		}
+	}
+	if count >= 0 && len(ids) > count {
+		ids = ids[:count]
+	}
+	for _, pid := range ids {
+		e, ok := st.entries.Find(streamEntry{id: pid})
+		if !ok {
+			res = append(res, StreamEntry{ID: pid.String()})
+			continue
+		}
+		res = append(res, e.export())
+	}
+	return res, nil
+}
+
+// XACK removes entries from the pending list of group and returns
+// how many of them were pending.
+func (r *Storage) XACK(key string, group string, ids []string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, grp, err := r.getGroup(key, group)
+	if err != nil {
+		return 0, err
+	}
+	parsed := make([]streamID, 0, len(ids))
+	for _, id := range ids {
+		pid, err := parseStreamID(id, 0)
+		if err != nil {
+			return 0, err
+		}
+		parsed = append(parsed, pid)
+	}
+
+	acked := 0
+	for _, pid := range parsed {
+		if _, ok := grp.pending[pid]; ok {
+			delete(grp.pending, pid)
+			acked++
+		}
+	}
+	return acked, nil
+}
+
+// XPENDING returns the pending list of group ordered by ID. Idle time
+// is in milliseconds.
+func (r *Storage) XPENDING(key string, group string) ([]PendingEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, grp, err := r.getGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	res := make([]PendingEntry, 0, len(grp.pending))
+	for _, pid := range grp.sortedPending() {
+		p := grp.pending[pid]
+		res = append(res, PendingEntry{
+			ID:         pid.String(),
+			Consumer:   p.consumer,
+			Idle:       now - p.deliveredAt,
+			Deliveries: p.deliveries,
+		})
+	}
+	return res, nil
+}
+
+func (grp *consumerGroup) sortedPending() []streamID {
+	ids := make([]streamID, 0, len(grp.pending))
+	for pid := range grp.pending {
+		ids = append(ids, pid)
+	}
+	slices.SortFunc(ids, func(a, b streamID) int {
+		if lessStreamID(a, b) {
+			return -1
+		}
+		return 1
+	})
+	return ids
+}
+
+// XCLAIM transfers pending entries that are idle for at least minIdle
+// to consumer and returns them. Entries that were trimmed from the stream
+// are dropped from the pending list.
+func (r *Storage) XCLAIM(key string, group string, consumer string, minIdle time.Duration, ids []string) ([]StreamEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	st, grp, err := r.getGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+	parsed := make([]streamID, 0, len(ids))
+	for _, id := range ids {
+		pid, err := parseStreamID(id, 0)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, pid)
+	}
+
+	now := time.Now().UnixMilli()
+	res := make([]StreamEntry, 0)
+	for _, pid := range parsed {
+		p, ok := grp.pending[pid]
+		if !ok || now-p.deliveredAt < minIdle.Milliseconds() {
+			continue
+		}
+		e, ok := st.entries.Find(streamEntry{id: pid})
+		if !ok {
+			delete(grp.pending, pid)
+			continue
+		}
+		p.consumer = consumer
+		p.deliveredAt = now
+		p.deliveries++
+		res = append(res, e.export())
+	}
+	return res, nil
+}
+
+type streamState struct {
+	LastID  string                `json:"lastid"`
+	Entries []streamEntryState    `json:"entries"`
+	Groups  map[string]groupState `json:"groups"`
+}
+
+type streamEntryState struct {
+	ID     string           `json:"id"`
+	Fields map[string]value `json:"fields"`
+}
+
+type groupState struct {
+	LastDelivered string         `json:"lastdelivered"`
+	Pending       []pendingState `json:"pending"`
+}
+
+type pendingState struct {
+	ID          string `json:"id"`
+	Consumer    string `json:"consumer"`
+	DeliveredAt int64  `json:"deliveredat"`
+	Deliveries  int    `json:"deliveries"`
+}
+
+func (r *Storage) getStreamState() map[string]streamState {
+	res := make(map[string]streamState, len(r.innerStream))
+	for key, st := range r.innerStream {
+		entries := make([]streamEntryState, 0, st.entries.Len())
+		for _, e := range st.entries.Slice(0, st.entries.Len()-1) {
+			entries = append(entries, streamEntryState{
+				ID:     e.id.String(),
+				Fields: e.fields,
+			})
+		}
+
+		groups := make(map[string]groupState, len(st.groups))
+		for name, grp := range st.groups {
+			pending := make([]pendingState, 0, len(grp.pending))
+			for pid, p := range grp.pending {
+				pending = append(pending, pendingState{
+					ID:          pid.String(),
+					Consumer:    p.consumer,
+					DeliveredAt: p.deliveredAt,
+					Deliveries:  p.deliveries,
+				})
+			}
+			groups[name] = groupState{
+				LastDelivered: grp.lastDelivered.String(),
+				Pending:       pending,
+			}
+		}
+
+		res[key] = streamState{
+			LastID:  st.lastID.String(),
+			Entries: entries,
+			Groups:  groups,
+		}
+	}
+	return res
+}
+
+func (r *Storage) recoverStreams(state map[string]streamState) {
+	for key, inStream := range state {
+		if r.isExpired(key) {
+			delete(r.innerExpire, key)
+			continue
+		}
+		if err := r.recoverStream(key, inStream); err != nil {
+			r.logger.Error(err.Error())
+		}
+	}
+}
+
+func (r *Storage) recoverStream(key string, state streamState) error {
+	st := newStream()
+	lastID, err := parseStreamID(state.LastID, 0)
+	if err != nil {
+		return err
+	}
+	st.lastID = lastID
+
+	for _, e := range state.Entries {
+		id, err := parseStreamID(e.ID, 0)
+		if err != nil {
+			return err
+		}
+		fields := make(map[string]value, len(e.Fields))
+		for field, val := range e.Fields {
+			fields[field], err = newValue(val.Val)
+			if err != nil {
+				return err
+			}
+		}
+		st.entries.Insert(streamEntry{id: id, fields: fields})
+	}
+
+	for name, grp := range state.Groups {
+		lastDelivered, err := parseStreamID(grp.LastDelivered, 0)
+		if err != nil {
+			return err
+		}
+		pending := make(map[streamID]*pendingEntry, len(grp.Pending))
+		for _, p := range grp.Pending {
+			pid, err := parseStreamID(p.ID, 0)
+			if err != nil {
+				return err
+			}
+			pending[pid] = &pendingEntry{
+				consumer:    p.Consumer,
+				deliveredAt: p.DeliveredAt,
+				deliveries:  p.Deliveries,
+			}
+		}
+		st.groups[name] = &consumerGroup{
+			lastDelivered: lastDelivered,
+			pending:       pending,
+		}
+	}
+
+	tempExp := r.innerExpire[key]
+	r.setStream(key, st)
+	r.innerExpire[key] = tempExp
+	return nil
+}
@@ -0,0 +1,435 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Sample is a value at a timestamp in milliseconds.
+type Sample struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+func lessSample(a, b Sample) bool {
+	return a.Timestamp < b.Timestamp
+}
+
+// tsAggregator accumulates samples of a bucket.
+type tsAggregator struct {
+	sum   float64
+	min   float64
+	max   float64
+	count int
+}
+
+func (a *tsAggregator) add(val float64) {
+	if a.count == 0 || val < a.min {
+		a.min = val
+	}
+	if a.count == 0 || val > a.max {
+		a.max = val
+	}
+	a.sum += val
+	a.count++
+}
+
+func (a *tsAggregator) value(aggregation string) float64 {
+	switch aggregation {
+	case "avg":
+		return a.sum / float64(a.count)
+	case "min":
+		return a.min
+	case "max":
+		return a.max
+	case "sum":
+		return a.sum
+	}
+	return float64(a.count)
+}
+
+func parseAggregation(aggregation string) (string, bool) {
+	aggregation = strings.ToLower(aggregation)
+	switch aggregation {
+	case "avg", "min", "max", "sum", "count":
+		return aggregation, true
+	}
+	return "", false
+}
+
+// bucketStart aligns ts down to a multiple of bucket.
+func bucketStart(ts int64, bucket int64) int64 {
+	return ts - ((ts%bucket)+bucket)%bucket
+}
+
+// CompactionRule writes aggregates of every Bucket milliseconds of
+// the source time series to Dst.
+type CompactionRule struct {
+	Dst         string `json:"dst"`
+	Aggregation string `json:"aggregation"`
+	Bucket      int64  `json:"bucket"`
+
+	// The latest bucket is aggregated incrementally while samples come
+	// in order. Other changes make the bucket be aggregated anew.
+	open  bool
+	start int64
+	acc   tsAggregator
+}
+
+// timeSeries keeps samples ordered by timestamp. Samples older than
+// retention milliseconds before the latest one are trimmed, zero
+// retention keeps samples forever. A time series that is a compaction
+// destination keeps the key of its source.
+type timeSeries struct {
+	retention int64
+	samples   *orderedTreap[Sample]
+	rules     []CompactionRule
+	source    string
+}
+
+func newTimeSeries(retention int64) *timeSeries {
+	return &timeSeries{
+		retention: retention,
+		samples:   newOrderedTreap(lessSample),
+	}
+}
+
+func (ts *timeSeries) last() (Sample, bool) {
+	n := ts.samples.Len()
+	if n == 0 {
+		return Sample{}, false
+	}
+	return ts.samples.Slice(n-1, n-1)[0], true
+}
+
+// oldest returns the oldest timestamp kept by retention.
+func (ts *timeSeries) oldest() int64 {
+	last, ok := ts.last()
+	if ts.retention == 0 || !ok {
+		return math.MinInt64
+	}
+	return last.Timestamp - ts.retention
+}
+
+// put adds the sample or replaces the value at its timestamp and reports
+// whether a sample was replaced.
+func (ts *timeSeries) put(s Sample) bool {
+	replaced := ts.samples.Delete(s)
+	ts.samples.Insert(s)
+	return replaced
+}
+
+// between returns samples with timestamps from from to to inclusive.
+func (ts *timeSeries) between(from int64, to int64) []Sample {
+	lo := ts.samples.CountWhile(func(s Sample) bool { return s.Timestamp < from })
+	hi := ts.samples.CountWhile(func(s Sample) bool { return s.Timestamp <= to })
+	return ts.samples.Slice(lo, hi-1)
+}
+
+func (ts *timeSeries) trim() {
+	oldest := ts.oldest()
+	for _, s := range ts.between(math.MinInt64, oldest-1) {
+		ts.samples.Delete(s)
+	}
+}
+
+func (ts *timeSeries) aggregate(from int64, to int64) tsAggregator {
+	var acc tsAggregator
+	for _, s := range ts.between(from, to) {
+		acc.add(s.Value)
+	}
+	return acc
+}
+
+// compact updates the buckets of destinations that contain s. If any
+// aggregate is infinite, nothing is updated and an error is returned.
+func (r *Storage) compact(ts *timeSeries, s Sample, replaced bool) error {
+	last, _ := ts.last()
+	rules := slices.Clone(ts.rules)
+	updates := make([]Sample, len(rules))
+	for i := range rules {
+		rule := &rules[i]
+		start := bucketStart(s.Timestamp, rule.Bucket)
+		var acc tsAggregator
+		switch {
+		case rule.open && start == rule.start && !replaced:
+			rule.acc.add(s.Value)
+			acc = rule.acc
+		case rule.open && start > rule.start:
+			rule.start = start
+			rule.acc = tsAggregator{}
+			rule.acc.add(s.Value)
+			acc = rule.acc
+		default:
+			acc = ts.aggregate(start, start+rule.Bucket-1)
+			if start == bucketStart(last.Timestamp, rule.Bucket) {
+				rule.open, rule.start, rule.acc = true, start, acc
+			}
+		}
+		updates[i] = Sample{Timestamp: start, Value: acc.value(rule.Aggregation)}
+		if math.IsInf(updates[i].Value, 0) {
+			return errors.New("ValueError: aggregate would overflow")
+		}
+	}
+
+	copy(ts.rules, rules)
+	for i, rule := range rules {
+		r.innerTimeSeries[rule.Dst].put(updates[i])
+	}
+	return nil
+}
+
+func (r *Storage) getTimeSeries(key string) (*timeSeries, error) {
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindTimeSeries && struct_kind != kindNoStruct {
+		return nil, errors.New("KeyError: this key already exists and has different type")
+	}
+	return r.innerTimeSeries[key], nil
+}
+
+func (r *Storage) setTimeSeries(key string, ts *timeSeries) {
+	r.innerTimeSeries[key] = ts
+	r.setKey(key, kindTimeSeries)
+	r.innerExpire[key] = 0
+}
+
+// unlinkTimeSeries removes compaction rules from and to the time series
+// by key before it is deleted.
+func (r *Storage) unlinkTimeSeries(key string) {
+	ts := r.innerTimeSeries[key]
+	if ts == nil {
+		return
+	}
+	if src := r.innerTimeSeries[ts.source]; src != nil {
+		src.rules = slices.DeleteFunc(src.rules, func(rule CompactionRule) bool {
+			return rule.Dst == key
+		})
+	}
+	for _, rule := range ts.rules {
+		if dst := r.innerTimeSeries[rule.Dst]; dst != nil {
+			dst.source = ""
+		}
+	}
+}
+
+// TSCREATE creates an empty time series keeping samples for retention
+// milliseconds. Zero retention keeps samples forever.
+func (r *Storage) TSCREATE(key string, retention int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if retention < 0 {
+		return errors.New("WrongArgs")
+	}
+	if r.getLiveStruct(key) != kindNoStruct {
+		return errors.New("KeyError: this key already exists")
+	}
+	r.setTimeSeries(key, newTimeSeries(retention))
+	return nil
+}
+
+// TSADD adds a sample to the time series by key, creating it without
+// retention if needed, and returns its timestamp. A sample with the same
+// timestamp is replaced. Compaction destinations are updated as well, and
+// the sample is rejected if one of their aggregates would be infinite.
+func (r *Storage) TSADD(key string, timestamp int64, value float64) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if timestamp < 0 {
+		return 0, errors.New("WrongArgs")
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, errors.New("ValueError: value is not a valid float")
+	}
+	ts, err := r.getTimeSeries(key)
+	if err != nil {
+		return 0, err
+	}
+	if ts == nil {
+		ts = newTimeSeries(0)
+		r.setTimeSeries(key, ts)
+	}
+	if timestamp < ts.oldest() {
+		return 0, errors.New("ValueError: timestamp is older than retention")
+	}
+
+	prev := ts.between(timestamp, timestamp)
+	s := Sample{Timestamp: timestamp, Value: value}
+	if err := r.compact(ts, s, ts.put(s)); err != nil {
+		ts.samples.Delete(s)
+		if len(prev) != 0 {
+			ts.put(prev[0])
+		}
+		return 0, err
+	}
+	return timestamp, nil
+}
+
+// TSRANGE returns samples with timestamps from from to to inclusive. With
+// aggregation (avg, min, max, sum or count) it returns an aggregate for
+// every non-empty bucket of bucket milliseconds instead.
+func (r *Storage) TSRANGE(key string, from int64, to int64, aggregation string, bucket int64) ([]Sample, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if aggregation != "" {
+		var ok bool
+		if aggregation, ok = parseAggregation(aggregation); !ok || bucket <= 0 {
+			return nil, errors.New("WrongArgs")
+		}
+	}
+	ts, err := r.getTimeSeries(key)
+	if err != nil {
+		return nil, err
+	}
+	if ts == nil {
+		return nil, errors.New("KeyError")
+	}
+
+	samples := ts.between(max(from, ts.oldest()), to)
+	if aggregation == "" {
+		return samples, nil
+	}
+
+	res := make([]Sample, 0)
+	var acc tsAggregator
+	for i, s := range samples {
+		acc.add(s.Value)
+		start := bucketStart(s.Timestamp, bucket)
+		if i+1 == len(samples) || bucketStart(samples[i+1].Timestamp, bucket) != start {
+			val := acc.value(aggregation)
+			if math.IsInf(val, 0) {
+				return nil, errors.New("ValueError: aggregate would overflow")
+			}
+			res = append(res, Sample{Timestamp: start, Value: val})
+			acc = tsAggregator{}
+		}
+	}
+	return res, nil
+}
+
+// TSCREATERULE makes TSADD on src write aggregates of every bucket
+// milliseconds to dst. Both time series must exist, and compactions
+// cannot be chained.
+func (r *Storage) TSCREATERULE(src string, dst string, aggregation string, bucket int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	aggregation, ok := parseAggregation(aggregation)
+	if !ok || bucket <= 0 || src == dst {
+		return errors.New("WrongArgs")
+	}
+	srcTS, err := r.getTimeSeries(src)
+	if err != nil {
+		return err
+	}
+	dstTS, err := r.getTimeSeries(dst)
+	if err != nil {
+		return err
+	}
+	if srcTS == nil || dstTS == nil {
+		return errors.New("KeyError")
+	}
+	if srcTS.source != "" || dstTS.source != "" || len(dstTS.rules) != 0 {
+		return errors.New("ValueError: compaction rules cannot be chained")
+	}
+
+	srcTS.rules = append(srcTS.rules, CompactionRule{
+		Dst:         dst,
+		Aggregation: aggregation,
+		Bucket:      bucket,
+	})
+	dstTS.source = src
+	return nil
+}
+
+// TSDELETERULE removes the compaction rule from src to dst. Samples
+// already written to dst are kept.
+func (r *Storage) TSDELETERULE(src string, dst string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	srcTS, err := r.getTimeSeries(src)
+	if err != nil {
+		return err
+	}
+	if srcTS == nil {
+		return errors.New("KeyError")
+	}
+	i := slices.IndexFunc(srcTS.rules, func(rule CompactionRule) bool {
+		return rule.Dst == dst
+	})
+	if i == -1 {
+		return errors.New("KeyError")
+	}
+	srcTS.rules = slices.Delete(srcTS.rules, i, i+1)
+	r.innerTimeSeries[dst].source = ""
+	return nil
+}
+
+func (r *Storage) trimTimeSeries() {
+	for _, ts := range r.innerTimeSeries {
+		ts.trim()
+	}
+}
+
+type timeSeriesState struct {
+	Retention int64            `json:"retention"`
+	Samples   []Sample         `json:"samples"`
+	Rules     []CompactionRule `json:"rules,omitempty"`
+}
+
+func (r *Storage) getTimeSeriesState() map[string]timeSeriesState {
+	res := make(map[string]timeSeriesState, len(r.innerTimeSeries))
+	for key, ts := range r.innerTimeSeries {
+		res[key] = timeSeriesState{
+			Retention: ts.retention,
+			Samples:   ts.between(math.MinInt64, math.MaxInt64),
+			Rules:     slices.Clone(ts.rules),
+		}
+	}
+	return res
+}
+
+func (r *Storage) recoverTimeSeries(state map[string]timeSeriesState) {
+	for key, inTS := range state {
+		if r.isExpired(key) {
+			delete(r.innerExpire, key)
+			continue
+		}
+		ts := newTimeSeries(max(inTS.Retention, 0))
+		for _, s := range inTS.Samples {
+			ts.put(s)
+		}
+
+		tempExp := r.innerExpire[key]
+		r.setTimeSeries(key, ts)
+		r.innerExpire[key] = tempExp
+	}
+
+	// Rules are restored once all time series exist, skipping rules
+	// to destinations that expired or were taken by another source.
+	for key, inTS := range state {
+		src := r.innerTimeSeries[key]
+		if src == nil {
+			continue
+		}
+		for _, rule := range inTS.Rules {
+			dst := r.innerTimeSeries[rule.Dst]
+			aggregation, ok := parseAggregation(rule.Aggregation)
+			if dst == nil || dst.source != "" || rule.Dst == key || !ok || rule.Bucket <= 0 {
+				r.logger.Error("Compaction rule from " + key + " to " + rule.Dst + " is broken")
+				continue
+			}
+			src.rules = append(src.rules, CompactionRule{
+				Dst:         rule.Dst,
+				Aggregation: aggregation,
+				Bucket:      rule.Bucket,
+			})
+			dst.source = key
+		}
+	}
+}
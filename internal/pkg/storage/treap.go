@@ -3,6 +3,7 @@ package storage
 import (
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 )
 
@@ -10,13 +11,89 @@ type node struct {
 	value value
 	prior int
 	size  int
+	// rev marks that the children of the subtree must be swapped
+	// before they are accessed.
+	rev bool
+	// agg keeps aggregates of kindInt values in the subtree.
+	agg aggregate
+	// mul and add is a lazy x*mul+add update of kindInt values
+	// that is already applied to the node but not to its children.
+	mul   int
+	add   int
 	left  *node
 	right *node
 }
 
+type aggregate struct {
+	Sum   int
+	Min   int
+	Max   int
+	Count int
+	// overflow marks that Sum does not fit in int and is not valid.
+	overflow bool
+}
+
+func addOverflows(a, b int) bool {
+	return (b > 0 && a > math.MaxInt-b) || (b < 0 && a < math.MinInt-b)
+}
+
+func mulOverflows(a, b int) bool {
+	if a == 0 || b == 0 {
+		return false
+	}
+	if (a == -1 && b == math.MinInt) || (b == -1 && a == math.MinInt) {
+		return true
+	}
+	return a*b/b != a
+}
+
+// mulAddOverflows reports whether x*mul+add does not fit in int.
+func mulAddOverflows(x, mul, add int) bool {
+	return mulOverflows(x, mul) || addOverflows(x*mul, add)
+}
+
+func combine(a, b aggregate) aggregate {
+	if a.Count == 0 {
+		return b
+	}
+	if b.Count == 0 {
+		return a
+	}
+	return aggregate{
+		Sum:      a.Sum + b.Sum,
+		Min:      min(a.Min, b.Min),
+		Max:      max(a.Max, b.Max),
+		Count:    a.Count + b.Count,
+		overflow: a.overflow || b.overflow || addOverflows(a.Sum, b.Sum),
+	}
+}
+
+func valueAggregate(val value) aggregate {
+	if val.Kin != kindInt {
+		return aggregate{}
+	}
+	num := toInt(val.Val)
+	return aggregate{
+		Sum:   num,
+		Min:   num,
+		Max:   num,
+		Count: 1,
+	}
+}
+
+func getAggregate(n *node) aggregate {
+	if n != nil {
+		return n.agg
+	}
+	return aggregate{}
+}
+
 type Treap struct {
 	root *node
 	mp   map[value]int
+	// mpDirty marks that mp is out of date after a range update
+	// and has to be rebuilt before it is read.
+	mpDirty bool
 }
 
 func newNode(val any) (*node, error) {
@@ -28,6 +105,8 @@ func newNode(val any) (*node, error) {
 		value: new_val,
 		prior: rand.Int(),
 		size:  1,
+		agg:   valueAggregate(new_val),
+		mul:   1,
 		left:  nil,
 		right: nil,
 	}, nil
@@ -48,6 +127,9 @@ func getSize(n *node) int {
 }
 
 func (trp Treap) incVal(val value) {
+	if trp.mpDirty {
+		return
+	}
 	if _, ok := trp.mp[val]; !ok {
 		trp.mp[val] = 1
 	} else {
@@ -56,7 +138,9 @@ func (trp Treap) incVal(val value) {
 }
 
 func (trp Treap) decVal(val value) {
-
+	if trp.mpDirty {
+		return
+	}
 	if cnt := trp.mp[val]; cnt != 1 {
 		trp.mp[val] -= 1
 	} else {
@@ -64,9 +148,63 @@ func (trp Treap) decVal(val value) {
 	}
 }
 
+// counts returns the value-count map, rebuilding it if it is out of date.
+func (trp *Treap) counts() map[value]int {
+	if trp.mpDirty {
+		trp.mp = make(map[value]int)
+		trp.mpDirty = false
+		for _, val := range trp.GetAllValues() {
+			trp.incVal(val)
+		}
+	}
+	return trp.mp
+}
+
+// apply updates every kindInt value x of the subtree to x*mul+add.
+// The node itself is updated at once, its children lazily on push.
+func apply(n *node, mul, add int) {
+	if n == nil {
+		return
+	}
+	if n.value.Kin == kindInt {
+		n.value.Val = toInt(n.value.Val)*mul + add
+	}
+	if mul == 0 {
+		n.agg.overflow = false
+	}
+	n.agg.overflow = n.agg.overflow || mulOverflows(n.agg.Sum, mul) || mulOverflows(add, n.agg.Count) ||
+		addOverflows(n.agg.Sum*mul, add*n.agg.Count)
+	n.agg.Sum = n.agg.Sum*mul + add*n.agg.Count
+	n.agg.Min, n.agg.Max = n.agg.Min*mul+add, n.agg.Max*mul+add
+	if mul < 0 {
+		n.agg.Min, n.agg.Max = n.agg.Max, n.agg.Min
+	}
+	n.mul *= mul
+	n.add = n.add*mul + add
+}
+
+func push(n *node) {
+	if n != nil && (n.mul != 1 || n.add != 0) {
+		apply(n.left, n.mul, n.add)
+		apply(n.right, n.mul, n.add)
+		n.mul, n.add = 1, 0
+	}
+	if n != nil && n.rev {
+		n.left, n.right = n.right, n.left
+		if n.left != nil {
+			n.left.rev = !n.left.rev
+		}
+		if n.right != nil {
+			n.right.rev = !n.right.rev
+		}
+		n.rev = false
+	}
+}
+
 func update(n *node) {
 	if n != nil {
 		n.size = getSize(n.left) + 1 + getSize(n.right)
+		n.agg = combine(combine(getAggregate(n.left), valueAggregate(n.value)), getAggregate(n.right))
 	}
 }
 
@@ -78,10 +216,12 @@ func merge(a, b *node) *node {
 		return a
 	}
 	if a.prior > b.prior {
+		push(a)
 		a.right = merge(a.right, b)
 		update(a)
 		return a
 	} else {
+		push(b)
 		b.left = merge(a, b.left)
 		update(b)
 		return b
@@ -92,6 +232,7 @@ func split(n *node, k int) (*node, *node) {
 	if n == nil {
 		return nil, nil
 	}
+	push(n)
 	if getSize(n.left) < k {
 		a, b := split(n.right, k-getSize(n.left)-1)
 		n.right = a
@@ -130,7 +271,7 @@ func (trp *Treap) PushBackToSet(val any) error {
 	if err != nil {
 		return err
 	}
-	if _, ok := trp.mp[new_node.value]; !ok {
+	if _, ok := trp.counts()[new_node.value]; !ok {
 		trp.PushBack(val)
 	}
 	return nil
@@ -161,12 +302,54 @@ func (trp *Treap) Set(index int, valToAdd any) bool {
 	equal, greater = split(greater, 1)
 	prev_val := equal.value
 	equal.value = new_val
+	update(equal)
 	trp.decVal(prev_val)
 	trp.incVal(new_val)
 	trp.root = merge(merge(less, equal), greater)
 	return true
 }
 
+func (trp *Treap) InsertAt(index int, val any) error {
+	if index < 0 || index > trp.GetSize() {
+		return errors.New("IndexOutOfRange")
+	}
+	new_node, err := newNode(val)
+	if err != nil {
+		return err
+	}
+	less, greater := split(trp.root, index)
+	trp.root = merge(merge(less, new_node), greater)
+	trp.incVal(new_node.value)
+	return nil
+}
+
+func (trp *Treap) Contains(val value) bool {
+	_, ok := trp.counts()[val]
+	return ok
+}
+
+// Positions returns indexes of all elements equal to val in ascending order.
+func (trp *Treap) Positions(val value) []int {
+	res := make([]int, 0)
+	if !trp.Contains(val) {
+		return res
+	}
+	positions(trp.root, val, 0, &res)
+	return res
+}
+
+func positions(n *node, val value, offset int, res *[]int) {
+	if n != nil {
+		push(n)
+		positions(n.left, val, offset, res)
+		idx := offset + getSize(n.left)
+		if n.value == val {
+			*res = append(*res, idx)
+		}
+		positions(n.right, val, idx+1, res)
+	}
+}
+
 func (trp *Treap) PopFront() any {
 	if trp.GetSize() < 1 {
 		return -1
@@ -203,8 +386,131 @@ func (trp *Treap) EraseSection(l, r int) []any {
 	return nodes
 }
 
+func (trp *Treap) Range(l, r int) []value {
+	var less, equal, greater *node
+	less, greater = split(trp.root, l)
+	equal, greater = split(greater, r-l+1)
+	nodes := make([]value, 0, getSize(equal))
+	traversal(equal, &nodes)
+	trp.root = merge(merge(less, equal), greater)
+	return nodes
+}
+
+// Aggregate returns sum, min, max and count of kindInt elements
+// from l to r inclusive in O(log n).
+func (trp *Treap) Aggregate(l, r int) aggregate {
+	var less, equal, greater *node
+	less, greater = split(trp.root, l)
+	equal, greater = split(greater, r-l+1)
+	res := getAggregate(equal)
+	trp.root = merge(merge(less, equal), greater)
+	return res
+}
+
+// ApplyRange updates every element x from l to r inclusive to x*mul+add
+// in O(log n). All elements of the range must be kindInt, and none of
+// them may overflow. Since x*mul+add is monotonic, it is enough to check
+// the minimum and the maximum of the range.
+func (trp *Treap) ApplyRange(l, r int, mul, add int) error {
+	var less, equal, greater *node
+	less, greater = split(trp.root, l)
+	equal, greater = split(greater, r-l+1)
+	var err error
+	agg := getAggregate(equal)
+	if agg.Count != getSize(equal) {
+		err = errors.New("ValueError: range contains string values")
+	} else if agg.Count > 0 && (mulAddOverflows(agg.Min, mul, add) || mulAddOverflows(agg.Max, mul, add)) {
+		err = errors.New("ValueError: increment would overflow")
+	} else {
+		apply(equal, mul, add)
+		trp.mpDirty = true
+	}
+	trp.root = merge(merge(less, equal), greater)
+	return err
+}
+
+// Concat moves all elements of other to the end of the treap in O(log n)
+// plus O(d) for merging value counts, where d is the number of distinct
+// values in the smaller treap. other becomes empty.
+func (trp *Treap) Concat(other *Treap) {
+	trp.root = merge(trp.root, other.root)
+	if trp.mpDirty || other.mpDirty {
+		trp.mpDirty = true
+	} else {
+		if len(other.mp) > len(trp.mp) {
+			trp.mp, other.mp = other.mp, trp.mp
+		}
+		for val, cnt := range other.mp {
+			trp.mp[val] += cnt
+		}
+	}
+	other.root = nil
+	other.mp = make(map[value]int)
+	other.mpDirty = false
+}
+
+// SplitAt moves elements starting from index into a new treap. Splitting
+// takes O(log n), and value counts are moved for the smaller part in
+// O(min(index, n-index)). If counts are already out of date after a range
+// update, both treaps rebuild them lazily on the next read instead.
+func (trp *Treap) SplitAt(index int) *Treap {
+	less, greater := split(trp.root, index)
+	trp.root = less
+	res := &Treap{
+		root:    greater,
+		mp:      make(map[value]int),
+		mpDirty: trp.mpDirty,
+	}
+	if trp.mpDirty {
+		return res
+	}
+
+	smaller := less
+	if getSize(greater) < getSize(less) {
+		smaller = greater
+	}
+	moved := make([]value, 0, getSize(smaller))
+	traversal(smaller, &moved)
+	for _, val := range moved {
+		trp.decVal(val)
+		res.incVal(val)
+	}
+	if smaller == less {
+		trp.mp, res.mp = res.mp, trp.mp
+	}
+	return res
+}
+
+// Reverse reverses elements from l to r inclusive in O(log n)
+// by marking the subtree with a lazy reverse flag.
+func (trp *Treap) Reverse(l, r int) {
+	var less, equal, greater *node
+	less, greater = split(trp.root, l)
+	equal, greater = split(greater, r-l+1)
+	if equal != nil {
+		equal.rev = !equal.rev
+	}
+	trp.root = merge(merge(less, equal), greater)
+}
+
+// Rotate moves the last k elements to the front of the treap.
+// Negative k moves the first -k elements to the back.
+func (trp *Treap) Rotate(k int) {
+	size := trp.GetSize()
+	if size == 0 {
+		return
+	}
+	k = ((k % size) + size) % size
+	if k == 0 {
+		return
+	}
+	less, greater := split(trp.root, size-k)
+	trp.root = merge(greater, less)
+}
+
 func (trp *Treap) traversalDelete(n *node, nodes *[]any) {
 	if n != nil {
+		push(n)
 		trp.traversalDelete(n.left, nodes)
 		res := n.value
 		trp.decVal(res)
@@ -219,6 +525,7 @@ func (trp *Treap) GetSize() int {
 
 func print(n *node) {
 	if n != nil {
+		push(n)
 		print(n.left)
 		fmt.Println(n.value)
 		print(n.right)
@@ -273,6 +580,7 @@ func (trp *Treap) ValidateEraseSlice(indexes []int, isfromleft bool) (int, int,
 
 func traversal(n *node, nodes *[]value) {
 	if n != nil {
+		push(n)
 		traversal(n.left, nodes)
 		res := n.value
 		*nodes = append(*nodes, res)
@@ -0,0 +1,452 @@
+package storage
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type ZMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// sortedSet keeps scores by member for O(1) lookups and the same members
+// in an order-statistic treap ordered by score and then by member.
+type sortedSet struct {
+	scores map[string]float64
+	order  *orderedTreap[ZMember]
+}
+
+func lessZMember(a, b ZMember) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.Member < b.Member
+}
+
+func newSortedSet() *sortedSet {
+	return &sortedSet{
+		scores: make(map[string]float64),
+		order:  newOrderedTreap(lessZMember),
+	}
+}
+
+// add sets score of member and returns true if member is new.
+func (zs *sortedSet) add(member string, score float64) bool {
+	old, ok := zs.scores[member]
+	if ok {
+		if old == score {
+			return false
+		}
+		zs.order.Delete(ZMember{Member: member, Score: old})
+	}
+	zs.scores[member] = score
+	zs.order.Insert(ZMember{Member: member, Score: score})
+	return !ok
+}
+
+func (zs *sortedSet) remove(member string) bool {
+	score, ok := zs.scores[member]
+	if !ok {
+		return false
+	}
+	delete(zs.scores, member)
+	zs.order.Delete(ZMember{Member: member, Score: score})
+	return true
+}
+
+func (zs *sortedSet) rank(member string) (int, bool) {
+	score, ok := zs.scores[member]
+	if !ok {
+		return 0, false
+	}
+	target := ZMember{Member: member, Score: score}
+	return zs.order.CountWhile(func(m ZMember) bool {
+		return lessZMember(m, target)
+	}), true
+}
+
+// rangeBound is a score or lex range limit. inf is -1 or 1 for
+// the unbounded "-" and "+" lex limits.
+type rangeBound[T cmp.Ordered] struct {
+	val  T
+	excl bool
+	inf  int
+}
+
+// covers reports whether v lies before the lower bound, or not after
+// the upper bound if upper is set.
+func (b rangeBound[T]) covers(v T, upper bool) bool {
+	switch b.inf {
+	case -1:
+		return false
+	case 1:
+		return true
+	}
+	c := cmp.Compare(v, b.val)
+	return c < 0 || (c == 0 && b.excl != upper)
+}
+
+// parseScoreBound parses score limits like "1.5", "(1.5", "-inf" and "+inf".
+func parseScoreBound(s string) (rangeBound[float64], error) {
+	var res rangeBound[float64]
+	if strings.HasPrefix(s, "(") {
+		res.excl = true
+		s = s[1:]
+	}
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(val) {
+		return res, errors.New("ValueError: min or max is not a float")
+	}
+	res.val = val
+	return res, nil
+}
+
+// parseLexBound parses lex limits like "[a", "(a", "-" and "+".
+func parseLexBound(s string) (rangeBound[string], error) {
+	var res rangeBound[string]
+	switch {
+	case s == "-":
+		res.inf = -1
+	case s == "+":
+		res.inf = 1
+	case strings.HasPrefix(s, "["):
+		res.val = s[1:]
+	case strings.HasPrefix(s, "("):
+		res.val = s[1:]
+		res.excl = true
+	default:
+		return res, errors.New("ValueError: min or max not valid string range item")
+	}
+	return res, nil
+}
+
+func (r *Storage) getZSet(key string) (*sortedSet, error) {
+	struct_kind := r.getLiveStruct(key)
+	if struct_kind != kindZSet && struct_kind != kindNoStruct {
+		return nil, errors.New("KeyError: this key already exists and has different type")
+	}
+	return r.innerZSet[key], nil
+}
+
+// ZADD sets scores of members in the sorted set by key and returns
+// how many of them were new.
+func (r *Storage) ZADD(key string, members []ZMember) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.zadd(key, members)
+}
+
+func (r *Storage) zadd(key string, members []ZMember) (int, error) {
+	if len(members) == 0 {
+		return 0, errors.New("WrongArgs")
+	}
+	for _, m := range members {
+		if math.IsNaN(m.Score) || math.IsInf(m.Score, 0) {
+			return 0, errors.New("ValueError: score is not a valid float")
+		}
+	}
+
+	zs, err := r.getZSet(key)
+	if err != nil {
+		return 0, err
+	}
+	if zs == nil {
+		zs = newSortedSet()
+		r.innerZSet[key] = zs
+		r.setKey(key, kindZSet)
+		r.innerExpire[key] = 0
+	}
+
+	added := 0
+	for _, m := range members {
+		if zs.add(m.Member, m.Score) {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// ZREM removes members from the sorted set by key and returns how many
+// of them were removed. An empty sorted set is deleted.
+func (r *Storage) ZREM(key string, members []string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	zs, err := r.getZSet(key)
+	if err != nil || zs == nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, member := range members {
+		if zs.remove(member) {
+			removed++
+		}
+	}
+	if len(zs.scores) == 0 {
+		r.deleteKey(key, kindZSet)
+	}
+	return removed, nil
+}
+
+func (r *Storage) ZCARD(key string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	zs, err := r.getZSet(key)
+	if err != nil || zs == nil {
+		return 0, err
+	}
+	return len(zs.scores), nil
+}
+
+// ZSCORE returns score of member or nil if there is no such member.
+func (r *Storage) ZSCORE(key string, member string) (*float64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	zs, err := r.getZSet(key)
+	if err != nil || zs == nil {
+		return nil, err
+	}
+	score, ok := zs.scores[member]
+	if !ok {
+		return nil, nil
+	}
+	return &score, nil
+}
+
+// ZINCRBY adds incr to score of member, creating it with score incr
+// if needed, and returns the new score. A score overflowing to infinity
+// is rejected and the member is left unchanged.
+func (r *Storage) ZINCRBY(key string, member string, incr float64) (float64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if math.IsNaN(incr) || math.IsInf(incr, 0) {
+		return 0, errors.New("ValueError: increment is not a valid float")
+	}
+
+	zs, err := r.getZSet(key)
+	if err != nil {
+		return 0, err
+	}
+	score := incr
+	if zs != nil {
+		score += zs.scores[member]
+	}
+	if math.IsInf(score, 0) {
+		return 0, errors.New("ValueError: increment would overflow")
+	}
+
+	if _, err := r.zadd(key, []ZMember{{Member: member, Score: score}}); err != nil {
+		return 0, err
+	}
+	return score, nil
+}
+
+// ZRANK returns position of member in ascending order of scores
+// or nil if there is no such member.
+func (r *Storage) ZRANK(key string, member string) (*int, error) {
+	return r.zrank(key, member, false)
+}
+
+// ZREVRANK returns position of member in descending order of scores
+// or nil if there is no such member.
+func (r *Storage) ZREVRANK(key string, member string) (*int, error) {
+	return r.zrank(key, member, true)
+}
+
+func (r *Storage) zrank(key string, member string, rev bool) (*int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	zs, err := r.getZSet(key)
+	if err != nil || zs == nil {
+		return nil, err
+	}
+	rank, ok := zs.rank(member)
+	if !ok {
+		return nil, nil
+	}
+	if rev {
+		rank = len(zs.scores) - 1 - rank
+	}
+	return &rank, nil
+}
+
+// ZRANGE returns members with positions from start to stop inclusive.
+// Negative positions count from the end. If rev is set, positions
+// are counted in descending order of scores.
+func (r *Storage) ZRANGE(key string, start int, stop int, rev bool) ([]ZMember, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	zs, err := r.getZSet(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]ZMember, 0)
+	if zs == nil {
+		return res, nil
+	}
+	size := len(zs.scores)
+	start, stop, ok := normalizeRange(start, stop, size)
+	if !ok {
+		return res, nil
+	}
+
+	if rev {
+		res = zs.order.Slice(size-1-stop, size-1-start)
+		slices.Reverse(res)
+		return res, nil
+	}
+	return zs.order.Slice(start, stop), nil
+}
+
+// ZRANGEBYSCORE returns members with scores between minBound and maxBound.
+// Bounds are inclusive unless prefixed with "(", "-inf" and "+inf"
+// are unbounded. offset and count limit the result, a negative count
+// means no limit. If rev is set, members go in descending order.
+func (r *Storage) ZRANGEBYSCORE(key string, minBound string, maxBound string, rev bool, offset int, count int) ([]ZMember, error) {
+	lo, err := parseScoreBound(minBound)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := parseScoreBound(maxBound)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.zrangeBy(key, func(m ZMember) bool {
+		return lo.covers(m.Score, false)
+	}, func(m ZMember) bool {
+		return hi.covers(m.Score, true)
+	}, rev, offset, count)
+}
+
+// ZRANGEBYLEX returns members between minBound and maxBound in lexicographical
+// order. Bounds are prefixed with "[" or "(", "-" and "+" are unbounded.
+// Members are expected to have equal scores.
+func (r *Storage) ZRANGEBYLEX(key string, minBound string, maxBound string, rev bool, offset int, count int) ([]ZMember, error) {
+	lo, err := parseLexBound(minBound)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := parseLexBound(maxBound)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.zrangeBy(key, func(m ZMember) bool {
+		return lo.covers(m.Member, false)
+	}, func(m ZMember) bool {
+		return hi.covers(m.Member, true)
+	}, rev, offset, count)
+}
+
+// zrangeBy returns members that are not below, but are under or at upper.
+// Both predicates must hold for a prefix of the members.
+func (r *Storage) zrangeBy(key string, below, upper func(ZMember) bool, rev bool, offset int, count int) ([]ZMember, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	zs, err := r.getZSet(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]ZMember, 0)
+	if zs == nil || offset < 0 {
+		return res, nil
+	}
+
+	lo := zs.order.CountWhile(below)
+	hi := zs.order.CountWhile(upper)
+	if offset >= hi-lo {
+		return res, nil
+	}
+	size := hi - lo - offset
+	if count >= 0 {
+		size = min(size, count)
+	}
+	if size == 0 {
+		return res, nil
+	}
+
+	if rev {
+		res = zs.order.Slice(hi-offset-size, hi-offset-1)
+		slices.Reverse(res)
+		return res, nil
+	}
+	return zs.order.Slice(lo+offset, lo+offset+size-1), nil
+}
+
+// ZPOPMIN removes and returns up to count members with the lowest scores.
+func (r *Storage) ZPOPMIN(key string, count int) ([]ZMember, error) {
+	return r.zpop(key, count, false)
+}
+
+// ZPOPMAX removes and returns up to count members with the highest scores.
+func (r *Storage) ZPOPMAX(key string, count int) ([]ZMember, error) {
+	return r.zpop(key, count, true)
+}
+
+func (r *Storage) zpop(key string, count int, fromMax bool) ([]ZMember, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if count < 0 {
+		return nil, errors.New("WrongArgs")
+	}
+
+	zs, err := r.getZSet(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]ZMember, 0)
+	if zs == nil || count == 0 {
+		return res, nil
+	}
+
+	size := len(zs.scores)
+	count = min(count, size)
+	if fromMax {
+		res = zs.order.Slice(size-count, size-1)
+		slices.Reverse(res)
+	} else {
+		res = zs.order.Slice(0, count-1)
+	}
+	for _, m := range res {
+		zs.remove(m.Member)
+	}
+	if len(zs.scores) == 0 {
+		r.deleteKey(key, kindZSet)
+	}
+	return res, nil
+}
+
+func (r *Storage) getZSetState() map[string][]ZMember {
+	res := make(map[string][]ZMember, len(r.innerZSet))
+	for key, zs := range r.innerZSet {
+		res[key] = zs.order.Slice(0, len(zs.scores)-1)
+	}
+	return res
+}
+
+func (r *Storage) recoverZSets(state map[string][]ZMember) {
+	for key, members := range state {
+		if r.isExpired(key) {
+			delete(r.innerExpire, key)
+			continue
+		}
+		tempExp := r.innerExpire[key]
+		r.zadd(key, members)
+		r.innerExpire[key] = tempExp
+	}
+}